@@ -3,49 +3,214 @@ package main
 import (
 	"GoABNF/abnf"
 	"GoABNF/automata"
-	"container/list"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 )
 
-func checkRegularExpression(ruleList *list.List) bool {
-	analyzer := abnf.NewRegularAnalyzer(ruleList)
+func checkRegularExpression(grammar *abnf.Grammar) bool {
+	analyzer := abnf.NewRegularAnalyzer(grammar)
 	println("=====================Regular Expressions Begin=====================")
-	for e := analyzer.GetRegularRules().Front(); e != nil; e = e.Next() {
-		println(e.Value.(*abnf.Rule).String())
+	for _, rule := range analyzer.GetRegularRules() {
+		println(rule.String())
 	}
 	println("=====================Regular Expressions End=======================")
 	println("=====================Nonregular Expressions Begin==================")
-	for e := analyzer.GetNonRegularRules().Front(); e != nil; e = e.Next() {
-		println(e.Value.(*abnf.Rule).String())
+	for _, rule := range analyzer.GetNonRegularRules() {
+		println(rule.String())
+		println("    " + analyzer.GetExplanation(rule))
 	}
 	println("=====================Nonregular Expressions End====================")
 	println("=====================Undefined Expressions Begin===================")
-	for e := analyzer.GetUndefinedRules().Front(); e != nil; e = e.Next() {
-		println(e.Value.(*abnf.Rule).String())
+	for _, rule := range analyzer.GetUndefinedRules() {
+		println(rule.String())
 	}
 	println("=====================Undefined Expressions End=====================")
-	return analyzer.GetNonRegularRules().Len() == 0 && analyzer.GetUndefinedRules().Len() == 0
+	println("=====================Missing Rules Begin===========================")
+	for _, missingRule := range analyzer.GetMissingRules() {
+		println(missingRule.String())
+	}
+	println("=====================Missing Rules End=============================")
+	return len(analyzer.GetNonRegularRules()) == 0 && len(analyzer.GetUndefinedRules()) == 0
+}
+
+//    递归的规则最多展开的嵌套深度
+const maxRecursionDepth = 3
+
+//    子集构造所允许的最大DFA状态数，超过则放弃转换
+const maxDFAStates = 100000
+
+//    生成NFA所用的规则：正则的规则（递归的规则已经改写为重复）之外，其余规则按最大深度展开
+func nfaRules(analyzer *abnf.RegularAnalyzer) []*abnf.Rule {
+	rules := make([]*abnf.Rule, 0)
+	rules = append(rules, analyzer.GetRegularRules()...)
+	rules = append(rules, analyzer.GetNonRegularRules()...)
+	rules = append(rules, analyzer.GetUndefinedRules()...)
+	return rules
+}
+
+//    从规则ruleName生成NFA，递归的规则最多展开maxDepth层（见NFAContext.SetMaxDepth），
+//    返回的NFAContext中记录了被截断和无法处理的规则
+func GenerateNFA(ruleName string, rules []*abnf.Rule, maxDepth int) (*automata.NFA, *abnf.NFAContext) {
+	context := abnf.NewNFAContext(abnf.NewGrammar2(rules).GetRuleMap())
+	context.SetMaxDepth(maxDepth)
+	startState := automata.NewNFAState()
+	acceptingState := automata.NewNFAState()
+	abnf.NewRuleName(ruleName).GetNFAStates(startState, acceptingState, context)
+	return automata.NewNFA2(startState, acceptingState), context
+}
+
+type DotWriter interface {
+	WriteDot(writer io.Writer, name string) error
+}
+
+func printToDot(fileName, name string, automaton DotWriter) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	defer f.Close()
+	if err := automaton.WriteDot(f, name); err != nil {
+		println(err.Error())
+		return
+	}
+	println(fileName + " print completed.")
+}
+
+//    以规范格式把ABNF文件输出到标准输出
+func formatFile(fileName string) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	defer f.Close()
+
+	p := abnf.NewParser(f)
+	grammar, err := p.Parse()
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	if err := abnf.NewFormatter().Print(os.Stdout, grammar, p.GetTrailingLines()); err != nil {
+		println(err.Error())
+	}
+}
+
+//    从规则startRule生成NFA并转换为最小DFA，DFA状态数超过maxDFAStates时报错；
+//    dotFile不为空时把最小DFA输出为DOT文件
+func printDFA(startRule, fileName, dotFile string) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	defer f.Close()
+
+	p := abnf.NewParser(f)
+	p.SetCoreRules(true)
+	grammar, err := p.Parse()
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	if grammar.Lookup(startRule) == nil {
+		println("Start rule " + startRule + " is not defined.")
+		return
+	}
+	nfa, _ := GenerateNFA(startRule, nfaRules(abnf.NewRegularAnalyzer(grammar)), maxRecursionDepth)
+	fmt.Printf("Total states = %d\n", len(nfa.GetStateSet()))
+
+	dfa, err := automata.NFA2DFA2(nfa, maxDFAStates)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	fmt.Printf("Total DFA states = %d\n", len(dfa.GetStates()))
+
+	minimization := automata.MinimizeDFA(dfa)
+	fmt.Printf("Minimal DFA states = %d (%d merged)\n", len(minimization.GetDFA().GetStates()), minimization.GetMergedCount())
+	if dotFile != "" {
+		printToDot(dotFile, startRule, minimization.GetDFA())
+	}
 }
 
-func GenerateNFA(ruleName string, regularRuleList *list.List) *automata.NFA {
-	rules := make(map[string]*abnf.Rule)
-	for e := regularRuleList.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*abnf.Rule)
-		rules[v.GetRuleName().String()] = v
+//    从多个ABNF文件中取出规则startRule引用到的所有规则，按依赖顺序输出到标准输出，
+//    引用不到的规则输出到标准错误
+//    每个参数是文件名，或者file=prefix，表示给文件中的规则名加上前缀prefix
+func printClosure(startRule string, fileNames []string) {
+	loader := abnf.NewLoader()
+	for _, fileName := range fileNames {
+		prefix := ""
+		//    规则名中不会出现'='，因此按最后一个'='分割
+		if i := strings.LastIndex(fileName, "="); i >= 0 {
+			fileName, prefix = fileName[:i], fileName[i+1:]
+		}
+		loader.AddFile(fileName, prefix)
+	}
+	loader.SetCoreRules(true)
+	grammar, err := loader.Load()
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	analyzer := abnf.NewRegularAnalyzer(grammar)
+	if grammar.Lookup(startRule) == nil {
+		println("Start rule " + startRule + " is not defined.")
+		return
+	}
+	for _, rule := range analyzer.GetUnreachableRules(startRule) {
+		println("Unreachable rule: " + rule.GetRuleName().String())
+	}
+	if err := abnf.NewFormatter().Print(os.Stdout, analyzer.GetClosure(startRule), nil); err != nil {
+		println(err.Error())
 	}
- 	startState := automata.NewNFAState();
-    acceptingState := automata.NewNFAState();
-    rules[ruleName].GetElements().GetNFAStates(startState, acceptingState, rules)
-	return automata.NewNFA2(startState, acceptingState)
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		println("Too few augments. Usage: GoABNF abnf.txt")
+	args := os.Args[1:]
+	//    -dot out.dot：把NFA（-dfa时是最小DFA）输出为Graphviz DOT文件，默认不输出
+	dotFile := ""
+	if len(args) > 0 && args[0] == "-dot" {
+		if len(args) < 2 {
+			println("Too few augments. Usage: GoABNF -dot out.dot abnf.txt [message.txt]")
+			return
+		}
+		dotFile = args[1]
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		println("Too few augments. Usage: GoABNF [-dot out.dot] abnf.txt [message.txt] | GoABNF -fmt abnf.txt | GoABNF -closure rule abnf.txt[=prefix]... | GoABNF [-dot out.dot] -dfa rule abnf.txt")
+		return
+	}
+	if args[0] == "-dfa" {
+		if len(args) < 3 {
+			println("Too few augments. Usage: GoABNF [-dot out.dot] -dfa rule abnf.txt")
+			return
+		}
+		printDFA(args[1], args[2], dotFile)
+		return
+	}
+	if args[0] == "-closure" {
+		if len(args) < 3 {
+			println("Too few augments. Usage: GoABNF -closure rule abnf.txt[=prefix]...")
+			return
+		}
+		printClosure(args[1], args[2:])
+		return
+	}
+	if args[0] == "-fmt" {
+		if len(args) < 2 {
+			println("Too few augments. Usage: GoABNF -fmt abnf.txt")
+			return
+		}
+		formatFile(args[1])
 		return
 	}
-	f, err := os.Open(os.Args[1])
+	f, err := os.Open(args[0])
 	if err != nil {
 		println(err.Error())
 		return
@@ -53,27 +218,44 @@ func main() {
 	defer f.Close()
 
 	p := abnf.NewParser(f)
-	ruleList, err := p.Parse()
+	p.SetCoreRules(true)
+	grammar, err := p.Parse()
 	if err != nil {
 		println(err.Error())
-		println("ruleList==nil")
+		println("grammar==nil")
 		return
 	}
-	for e := ruleList.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*abnf.Rule)
-		fmt.Printf("%s\n", v.String())
+	for _, rule := range grammar.GetRules() {
+		fmt.Printf("%s\n", rule.String())
 	}
 
-	if !checkRegularExpression(ruleList) {
+	if !checkRegularExpression(grammar) {
 		println("Error: There are non-regular expressions.")
 	}
 
-	regularAnalyzer := abnf.NewRegularAnalyzer(ruleList)
-	regularRuleList := regularAnalyzer.GetRegularRules()
-
-	nfa := GenerateNFA("RFC3261-SIP-message", regularRuleList)
-	//nfa.GetStartState().printToDot();
+	startRule := "RFC3261-SIP-message"
+	regularAnalyzer := abnf.NewRegularAnalyzer(grammar)
+	for _, rule := range regularAnalyzer.GetUnreachableRules(startRule) {
+		fmt.Printf("Unreachable rule from %s: %s\n", startRule, rule.GetRuleName().String())
+	}
+	nfa, context := GenerateNFA(startRule, nfaRules(regularAnalyzer), maxRecursionDepth)
 	fmt.Printf("Total states = %d\n", len(nfa.GetStateSet()))
-	//nfa.getStartState().printToDot();
-	//println("NFA print completed.");
+	for _, truncated := range context.GetTruncatedRules() {
+		fmt.Printf("Recursion of %s is unrolled to depth %d, the NFA is an under-approximation\n", truncated, maxRecursionDepth)
+	}
+	for _, unhandled := range context.GetUnhandledRules() {
+		fmt.Printf("Unhandled rule: %s\n", context.GetUnhandledReason(unhandled))
+	}
+	if dotFile != "" {
+		printToDot(dotFile, startRule, nfa)
+	}
+
+	if len(args) > 1 {
+		message, err := ioutil.ReadFile(args[1])
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		fmt.Printf("%s: %s\n", args[1], nfa.MatchPrefix(message).String())
+	}
 }
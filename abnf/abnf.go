@@ -6,6 +6,6 @@ import (
 
 type Abnf interface{
     GetDependentRuleNames() Set_RuleName;
-    GetNFA(rules map[string]*Rule) *automata.NFA
-    GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule)
+    GetNFA(context *NFAContext) *automata.NFA
+    GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext)
 }
@@ -3,38 +3,41 @@ package abnf
 import (
 	"GoABNF/automata"
 	"bytes"
-	"container/list"
 )
 
 //  alternation    =  concatenation
 //                          *(*c-wsp "/" *c-wsp concatenation)
 type Alternation struct {
-	concatenations *list.List
+	Span
+	concatenations []*Concatenation
 }
 
 func NewAlternation() *Alternation {
 	this := &Alternation{}
-	this.concatenations = list.New()
+	this.concatenations = make([]*Concatenation, 0)
 	return this
 }
 
 func (this *Alternation) AddConcatenation(concatenation *Concatenation) {
-	this.concatenations.PushBack(concatenation)
+	this.concatenations = append(this.concatenations, concatenation)
 }
 
-func (this *Alternation) GetConcatenations() *list.List {
+func (this *Alternation) GetConcatenations() []*Concatenation {
 	return this.concatenations
 }
 
+func (this *Alternation) SetConcatenations(concatenations []*Concatenation) {
+	this.concatenations = concatenations
+}
+
 func (this *Alternation) String() string {
 	var s bytes.Buffer
 
-	for e := this.concatenations.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*Concatenation)
-		s.WriteString(v.String())
-		if e.Next() != nil {
+	for i, v := range this.concatenations {
+		if i > 0 {
 			s.WriteString("/")
 		}
+		s.WriteString(v.String())
 	}
 
 	return s.String()
@@ -42,29 +45,28 @@ func (this *Alternation) String() string {
 
 func (this *Alternation) GetDependentRuleNames() Set_RuleName {
 	ruleNames := make(Set_RuleName)
-	for e := this.concatenations.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*Concatenation)
+	for _, v := range this.concatenations {
 		s := v.GetDependentRuleNames()
 		for _, r := range s {
-			ruleNames[r.rulename] = r
+			ruleNames[r.GetKey()] = r
 		}
 	}
 	return ruleNames
 }
 
-func (this *Alternation) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Alternation) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Alternation) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
-	if this.concatenations.Len() == 0 {
+func (this *Alternation) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
+	if len(this.concatenations) == 0 {
 		panic("Alternation is empty.")
 	}
 
-	for e := this.concatenations.Front(); e != nil; e = e.Next() {
-		e.Value.(*Concatenation).GetNFAStates(startState, acceptingState, rules)
+	for _, v := range this.concatenations {
+		v.GetNFAStates(startState, acceptingState, context)
 	}
 }
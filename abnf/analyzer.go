@@ -1,84 +1,288 @@
 package abnf
 
 import (
-	"container/list"
+	"bytes"
+	"strings"
 )
 
+//    规则的分类
+type RuleClass int
+
+const (
+	//    不依赖递归规则和未定义规则，可以生成NFA
+	RULE_REGULAR RuleClass = iota
+	//    直接引用了自己，并且无法改写为重复（见RewriteRecursion），例如自嵌入的规则
+	RULE_SELF_RECURSIVE
+	//    通过其他规则间接引用了自己，并且无法改写为重复（见RewriteComponent）
+	RULE_MUTUALLY_RECURSIVE
+	//    本身不递归，但依赖递归的规则
+	RULE_DEPENDS_ON_RECURSIVE
+	//    直接或间接依赖没有定义的规则
+	RULE_DEPENDS_ON_UNDEFINED
+	//    头递归或尾递归（包括互相递归），已经改写为重复，可以生成NFA
+	RULE_LINEAR_RECURSIVE
+)
+
+//    根据规则的依赖图（见DependencyGraph）对规则进行分类
 type RegularAnalyzer struct {
-	nonRegularRules *list.List // new ArrayList<Rule>();
-	regularRules    *list.List //= new ArrayList<Rule>();
-	undefinedRules  *list.List //= new ArrayList<Rule>();
+	graph *DependencyGraph
+
+	classes map[*Rule]RuleClass
+
+	//    线性递归的规则改写后的形式
+	rewritten map[*Rule]*Rule
+
+	//    规则不是正则的原因
+	explanations map[*Rule]string
+
+	nonRegularRules []*Rule
+	regularRules    []*Rule
+	undefinedRules  []*Rule
+
+	//    被引用但没有定义的规则，按第一次被引用的顺序排列
+	missingRules []*MissingRule
+}
+
+//    规则定义中对另一条规则的一处引用
+type Reference struct {
+	rule     *Rule
+	ruleName *RuleName
+}
+
+//    引用所在的规则
+func (this *Reference) GetRule() *Rule {
+	return this.rule
+}
+
+//    引用处的规则名，GetStart()是它在文法文件中的位置
+func (this *Reference) GetRuleName() *RuleName {
+	return this.ruleName
 }
 
-func NewRegularAnalyzer(rules *list.List) *RegularAnalyzer {
+//    引用所在的文法文件，未知时为空字符串
+func (this *Reference) GetFileName() string {
+	return this.rule.GetFileName()
+}
+
+//    引用所在的规则及位置，已知文件时位置写成file:line:col
+func (this *Reference) String() string {
+	location := this.ruleName.GetStart().String()
+	if this.GetFileName() != "" {
+		location = this.GetFileName() + ":" + location
+	}
+	return this.rule.GetRuleName().String() + " (" + location + ")"
+}
+
+//    没有定义的规则名，以及引用它的每一处
+type MissingRule struct {
+	name       string
+	references []*Reference
+}
+
+//    第一处引用的写法
+func (this *MissingRule) GetName() string {
+	return this.name
+}
+
+func (this *MissingRule) GetReferences() []*Reference {
+	return this.references
+}
+
+func (this *MissingRule) String() string {
+	var s bytes.Buffer
+	s.WriteString(this.name)
+	s.WriteString(" is referenced by ")
+	for i, reference := range this.references {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(reference.String())
+	}
+	return s.String()
+}
+
+func NewRegularAnalyzer(grammar *Grammar) *RegularAnalyzer {
 	this := &RegularAnalyzer{}
 
-	this.nonRegularRules = list.New() // new ArrayList<Rule>();
-	this.regularRules = list.New()    //= new ArrayList<Rule>();
-	this.undefinedRules = list.New()  //= new ArrayList<Rule>();
+	this.graph = NewDependencyGraph(grammar)
+	this.classes = make(map[*Rule]RuleClass)
+	this.rewritten = make(map[*Rule]*Rule)
+	this.explanations = make(map[*Rule]string)
+	this.nonRegularRules = make([]*Rule, 0)
+	this.regularRules = make([]*Rule, 0)
+	this.undefinedRules = make([]*Rule, 0)
+	this.missingRules = make([]*MissingRule, 0)
 
-	definedRuleNames := make(Set_RuleName)      // new HashSet<RuleName>();
-	observedRules := make([]*Rule, rules.Len()) //new ArrayList<Rule>();
-	for i, e := 0, rules.Front(); e != nil; i, e = i+1, e.Next() {
-		observedRules[i] = e.Value.(*Rule)
-		//println(observedRules[i].GetRuleName().String());
+	//    强连通分量按逆拓扑序排列，分类一条规则时它依赖的规则都已经分类
+	for _, component := range this.graph.GetComponents() {
+		for _, rule := range component {
+			this.classes[rule] = this.classify(rule)
+		}
 	}
 
-	foundRegular := true
-	for foundRegular {
-		foundRegular = false
-		var index int
-		//println("=======");
-		for index = len(observedRules) - 1; index >= 0; index-- {
-			if observedRules[index] == nil {
-				continue
-			}
-			//println(observedRules[index].GetElements().String());
-			dependent := observedRules[index].GetElements().GetDependentRuleNames()
-
-			if this.ContainsAll(definedRuleNames, dependent) {
-				definedRuleNames[observedRules[index].GetRuleName().String()] = observedRules[index].GetRuleName()
-				this.regularRules.PushBack(observedRules[index])
-				observedRules[index] = nil //.remove(index);
-				foundRegular = true
-				continue
+	for _, rule := range grammar.GetRules() {
+		switch this.classes[rule] {
+		case RULE_REGULAR, RULE_LINEAR_RECURSIVE:
+			this.regularRules = append(this.regularRules, this.GetRewrittenRule(rule))
+		case RULE_DEPENDS_ON_UNDEFINED:
+			this.undefinedRules = append(this.undefinedRules, rule)
+		default:
+			this.nonRegularRules = append(this.nonRegularRules, rule)
+		}
+	}
+
+	this.findMissingRules(grammar)
+	return this
+}
+
+//    按规则的定义顺序以及引用在规则中出现的顺序，收集每一处对没有定义的规则的引用
+func (this *RegularAnalyzer) findMissingRules(grammar *Grammar) {
+	missing := make(map[string]*MissingRule)
+	for _, rule := range grammar.GetRules() {
+		Inspect(rule.GetElements(), func(node Node) bool {
+			ruleName, ok := node.(*RuleName)
+			if !ok || grammar.Lookup(ruleName.GetKey()) != nil {
+				return true
 			}
-			
-			if _, present := dependent[observedRules[index].GetRuleName().String()]; !present {
-				continue
+			missingRule, present := missing[ruleName.GetKey()]
+			if !present {
+				missingRule = &MissingRule{ruleName.String(), make([]*Reference, 0)}
+				missing[ruleName.GetKey()] = missingRule
+				this.missingRules = append(this.missingRules, missingRule)
 			}
+			missingRule.references = append(missingRule.references, &Reference{rule, ruleName})
+			return true
+		})
+	}
+}
 
-			delete(dependent, observedRules[index].GetRuleName().String())
-			if this.ContainsAll(definedRuleNames, dependent) {
-				definedRuleNames[observedRules[index].GetRuleName().String()] = observedRules[index].GetRuleName()
-				this.nonRegularRules.PushBack(observedRules[index])
-				observedRules[index] = nil //.remove(index);
-				foundRegular = true
-			}
+func (this *RegularAnalyzer) classify(rule *Rule) RuleClass {
+	name := rule.GetRuleName().GetKey()
+	class := RULE_REGULAR
+	if this.graph.IsMutuallyRecursive(name) {
+		component := this.graph.GetComponent(name)
+		if _, present := this.explanations[rule]; !present && this.rewritten[rule] == nil {
+			this.rewriteComponent(component)
 		}
+		if _, present := this.explanations[rule]; present {
+			return RULE_MUTUALLY_RECURSIVE
+		}
+		class = RULE_LINEAR_RECURSIVE
+	} else if this.graph.IsSelfRecursive(name) {
+		rewritten, err := RewriteRecursion(rule)
+		if err != nil {
+			this.explanations[rule] = err.Error()
+			return RULE_SELF_RECURSIVE
+		}
+		this.rewritten[rule] = rewritten
+		class = RULE_LINEAR_RECURSIVE
 	}
-	for index := 0; index < len(observedRules); index++ {
-		if observedRules[index] != nil {
-			this.undefinedRules.PushBack(observedRules[index])
+	//    改写后的递归规则包含了同一强连通分量中其他规则的定义，因此检查整个分量的依赖
+	component := this.graph.GetComponent(name)
+	dependencies := make([]string, 0)
+	for _, member := range component {
+		dependencies = append(dependencies, this.graph.GetDependencies(member.GetRuleName().GetKey())...)
+	}
+	for _, dependency := range dependencies {
+		if other := this.graph.GetComponent(dependency); other != nil && other[0] == component[0] {
+			continue
+		}
+		defined := this.graph.GetGrammar().Lookup(dependency)
+		if defined == nil || this.classes[defined] == RULE_DEPENDS_ON_UNDEFINED {
+			delete(this.rewritten, rule)
+			return RULE_DEPENDS_ON_UNDEFINED
+		}
+		if this.classes[defined] != RULE_REGULAR && this.classes[defined] != RULE_LINEAR_RECURSIVE {
+			this.explanations[rule] = rule.GetRuleName().String() + " depends on non-regular rule " + defined.GetRuleName().String() + "."
+			class = RULE_DEPENDS_ON_RECURSIVE
 		}
 	}
-	//observedRules.clear();
-
-	return this
+	if class == RULE_DEPENDS_ON_RECURSIVE {
+		delete(this.rewritten, rule)
+	}
+	return class
 }
 
-func (this *RegularAnalyzer) ContainsAll(definedRuleNames Set_RuleName, dependent Set_RuleName) bool {
-	for _, r := range dependent {
-		_, present := definedRuleNames[r.String()]
-		if !present {
-			return false
+//    改写一组互相递归的规则，失败时为每条规则记录原因
+func (this *RegularAnalyzer) rewriteComponent(component []*Rule) {
+	rewritten, err := RewriteComponent(component)
+	for i, rule := range component {
+		if err != nil {
+			others := make([]string, 0)
+			for _, other := range component {
+				if other != rule {
+					others = append(others, other.GetRuleName().String())
+				}
+			}
+			this.explanations[rule] = rule.GetRuleName().String() + " is mutually recursive with " + strings.Join(others, ", ") + ": " + err.Error()
+		} else {
+			this.rewritten[rule] = rewritten[i]
 		}
 	}
-	return true
 }
 
-func (this *RegularAnalyzer) GetNonRegularRules() *list.List { return this.nonRegularRules }
+func (this *RegularAnalyzer) GetDependencyGraph() *DependencyGraph { return this.graph }
 
-func (this *RegularAnalyzer) GetRegularRules() *list.List { return this.regularRules }
+//    返回规则的分类
+func (this *RegularAnalyzer) GetRuleClass(rule *Rule) RuleClass { return this.classes[rule] }
+
+//    返回规则不是正则的原因，规则是正则的时候返回空字符串
+func (this *RegularAnalyzer) GetExplanation(rule *Rule) string { return this.explanations[rule] }
+
+//    返回头递归或尾递归的规则改写为重复之后的形式，其他规则原样返回
+func (this *RegularAnalyzer) GetRewrittenRule(rule *Rule) *Rule {
+	if rewritten, present := this.rewritten[rule]; present {
+		return rewritten
+	}
+	return rule
+}
 
-func (this *RegularAnalyzer) GetUndefinedRules() *list.List { return this.undefinedRules }
+//    无法改写的递归规则以及依赖它们的规则
+func (this *RegularAnalyzer) GetNonRegularRules() []*Rule { return this.nonRegularRules }
+
+//    可以生成NFA的规则，线性递归的规则是改写之后的形式
+func (this *RegularAnalyzer) GetRegularRules() []*Rule { return this.regularRules }
+
+//    直接或间接依赖没有定义的规则的规则。这些规则本身是有定义的，
+//    没有定义的规则名以及引用它们的位置见GetMissingRules
+func (this *RegularAnalyzer) GetUndefinedRules() []*Rule { return this.undefinedRules }
+
+//    被引用但没有在文法中定义的规则，以及引用它们的规则和位置
+func (this *RegularAnalyzer) GetMissingRules() []*MissingRule { return this.missingRules }
+
+//    直接引用了自己，并且无法改写为重复的规则
+func (this *RegularAnalyzer) GetSelfRecursiveRules() []*Rule {
+	return this.GetRulesOfClass(RULE_SELF_RECURSIVE)
+}
+
+//    通过其他规则间接引用了自己，并且无法改写为重复的规则
+func (this *RegularAnalyzer) GetMutuallyRecursiveRules() []*Rule {
+	return this.GetRulesOfClass(RULE_MUTUALLY_RECURSIVE)
+}
+
+//    从规则start出发引用不到的规则，按定义顺序排列
+func (this *RegularAnalyzer) GetUnreachableRules(start string) []*Rule {
+	return this.graph.GetUnreachableRules(start)
+}
+
+//    只包含从规则start出发引用到的规则的Grammar，规则按依赖顺序排列，
+//    可以用来从完整的RFC文法中自动生成类似SIP-Closure.txt的闭包文件
+func (this *RegularAnalyzer) GetClosure(start string) *Grammar {
+	return this.graph.Prune(start)
+}
+
+//    头递归或尾递归（包括互相递归）的规则，返回的是原来的形式，改写后的形式见GetRewrittenRule
+func (this *RegularAnalyzer) GetLinearRecursiveRules() []*Rule {
+	return this.GetRulesOfClass(RULE_LINEAR_RECURSIVE)
+}
+
+//    按定义顺序返回某一类的规则
+func (this *RegularAnalyzer) GetRulesOfClass(class RuleClass) []*Rule {
+	rules := make([]*Rule, 0)
+	for _, rule := range this.graph.GetGrammar().GetRules() {
+		if this.classes[rule] == class {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,133 @@
+package abnf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMissingRules(t *testing.T) {
+	grammar, err := NewParser(strings.NewReader("a = zz b\r\nb = \"x\" ZZ / yy\r\n")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := make([]string, 0)
+	for _, missingRule := range NewRegularAnalyzer(grammar).GetMissingRules() {
+		missing = append(missing, missingRule.String())
+	}
+	want := []string{"zz is referenced by a (1:5), b (2:9)", "yy is referenced by b (2:14)"}
+	if !reflect.DeepEqual(missing, want) {
+		t.Errorf("got %q, want %q", missing, want)
+	}
+
+	//    从多个文件加载的文法，位置中带有文件名
+	loader := NewLoader()
+	loader.AddReader("a.abnf", strings.NewReader("u = zz\r\n"), "")
+	loader.AddReader("b.abnf", strings.NewReader("v = \"x\" zz\r\n"), "")
+	grammar, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingRules := NewRegularAnalyzer(grammar).GetMissingRules()
+	if len(missingRules) != 1 || missingRules[0].String() != "zz is referenced by u (a.abnf:1:5), v (b.abnf:1:9)" {
+		t.Errorf("got %v", missingRules)
+	}
+	if file := missingRules[0].GetReferences()[1].GetFileName(); file != "b.abnf" {
+		t.Errorf("file of the second reference = %q", file)
+	}
+}
+
+func TestRuleClass(t *testing.T) {
+	grammar := NewGrammar2(parseRules(t,
+		"p = \"(\" p \")\" / \"x\"\n"+
+			"l = \"x\" [l]\n"+
+			"m = \"(\" n / \"y\"\n"+
+			"n = m \")\"\n"+
+			"r = \"x\" q / \"y\"\n"+
+			"q = \"z\" r\n"+
+			"d = p \".\"\n"+
+			"u = zz\n"+
+			"w = \"w\" u\n"+
+			"g = \"g\" l r\n"))
+	analyzer := NewRegularAnalyzer(grammar)
+	want := map[string]RuleClass{
+		"p": RULE_SELF_RECURSIVE,
+		"l": RULE_LINEAR_RECURSIVE,
+		"m": RULE_MUTUALLY_RECURSIVE,
+		"n": RULE_MUTUALLY_RECURSIVE,
+		"r": RULE_LINEAR_RECURSIVE,
+		"q": RULE_LINEAR_RECURSIVE,
+		"d": RULE_DEPENDS_ON_RECURSIVE,
+		"u": RULE_DEPENDS_ON_UNDEFINED,
+		"w": RULE_DEPENDS_ON_UNDEFINED,
+		"g": RULE_REGULAR,
+	}
+	for name, class := range want {
+		if got := analyzer.GetRuleClass(grammar.Lookup(name)); got != class {
+			t.Errorf("%s: class = %d, want %d", name, got, class)
+		}
+	}
+
+	if names := ruleNames(analyzer.GetSelfRecursiveRules()); !reflect.DeepEqual(names, []string{"p"}) {
+		t.Errorf("self recursive = %v", names)
+	}
+	if names := ruleNames(analyzer.GetMutuallyRecursiveRules()); !reflect.DeepEqual(names, []string{"m", "n"}) {
+		t.Errorf("mutually recursive = %v", names)
+	}
+	if names := ruleNames(analyzer.GetNonRegularRules()); !reflect.DeepEqual(names, []string{"p", "m", "n", "d"}) {
+		t.Errorf("non-regular = %v", names)
+	}
+	if names := ruleNames(analyzer.GetUndefinedRules()); !reflect.DeepEqual(names, []string{"u", "w"}) {
+		t.Errorf("undefined = %v", names)
+	}
+	if explanation := analyzer.GetExplanation(grammar.Lookup("d")); explanation != "d depends on non-regular rule p." {
+		t.Errorf("explanation of d = %q", explanation)
+	}
+	if explanation := analyzer.GetExplanation(grammar.Lookup("g")); explanation != "" {
+		t.Errorf("explanation of g = %q", explanation)
+	}
+	if rewritten := analyzer.GetRewrittenRule(grammar.Lookup("g")); rewritten != grammar.Lookup("g") {
+		t.Errorf("regular rule is rewritten to %s", rewritten)
+	}
+	if rewritten := analyzer.GetRewrittenRule(grammar.Lookup("l")); rewritten == grammar.Lookup("l") {
+		t.Errorf("linear recursive rule is not rewritten")
+	}
+}
+
+func TestClosure(t *testing.T) {
+	//    规则按依赖顺序输出：被依赖的规则在前，同一强连通分量中的规则按定义顺序
+	grammar := NewGrammar2(parseRules(t,
+		"start = head tail\n"+
+			"unused = \"u\" start\n"+
+			"tail = \"t\" / item tail\n"+
+			"head = item [sep head]\n"+
+			"item = even / odd\n"+
+			"odd = \"o\" [even]\n"+
+			"even = \"e\" [odd]\n"+
+			"sep = \",\"\n"))
+	analyzer := NewRegularAnalyzer(grammar)
+	closure := analyzer.GetClosure("start")
+	if names := ruleNames(closure.GetRules()); !reflect.DeepEqual(names, []string{"odd", "even", "item", "sep", "head", "tail", "start"}) {
+		t.Errorf("closure = %v", names)
+	}
+	var buffer bytes.Buffer
+	printer := NewFormatter()
+	printer.SetNewline("\n")
+	if err := printer.Print(&buffer, closure, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "odd   = \"o\" [even]\n" +
+		"even  = \"e\" [odd]\n" +
+		"item  = even / odd\n" +
+		"sep   = \",\"\n" +
+		"head  = item [sep head]\n" +
+		"tail  = \"t\" / item tail\n" +
+		"start = head tail\n"
+	if buffer.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buffer.String(), want)
+	}
+	if names := ruleNames(analyzer.GetUnreachableRules("start")); !reflect.DeepEqual(names, []string{"unused"}) {
+		t.Errorf("unreachable = %v", names)
+	}
+}
@@ -8,18 +8,73 @@ import (
 //                       ; quoted string of SP and VCHAR
 //                          without DQUOTE
 
+//RFC 7405:
+//char-val       =  case-insensitive-string /
+//                  case-sensitive-string
+//
+//case-insensitive-string =
+//                  [ "%i" ] quoted-string
+//
+//case-sensitive-string =
+//                  "%s" quoted-string
+
+type CaseSensitivity int
+
+const (
+	//没有前缀的字符串，大小写是否敏感由NFA构造时的选项决定（默认不敏感）
+	CASE_DEFAULT CaseSensitivity = iota
+	//%s"..."，大小写敏感
+	CASE_SENSITIVE
+	//%i"..."，大小写不敏感
+	CASE_INSENSITIVE
+)
+
 type CharVal struct { //implements Element {
-	value string
+	Span
+	value       string
+	sensitivity CaseSensitivity
 }
 
 func NewCharVal(value string) *CharVal {
+	return NewCharVal2(value, CASE_DEFAULT)
+}
+
+func NewCharVal2(value string, sensitivity CaseSensitivity) *CharVal {
 	this := &CharVal{}
 	this.value = value
+	this.sensitivity = sensitivity
 	return this
 }
 
+func (this *CharVal) GetValue() string {
+	return this.value
+}
+
+func (this *CharVal) GetCaseSensitivity() CaseSensitivity {
+	return this.sensitivity
+}
+
+//按照CharVal自身的前缀和NFA构造选项，判断是否大小写敏感
+func (this *CharVal) IsCaseSensitive(context *NFAContext) bool {
+	switch this.sensitivity {
+	case CASE_SENSITIVE:
+		return true
+	case CASE_INSENSITIVE:
+		return false
+	default:
+		return context.IsCaseSensitive()
+	}
+}
+
 func (this *CharVal) String() string {
-	return "\"" + this.value + "\""
+	switch this.sensitivity {
+	case CASE_SENSITIVE:
+		return "%s\"" + this.value + "\""
+	case CASE_INSENSITIVE:
+		return "%i\"" + this.value + "\""
+	default:
+		return "\"" + this.value + "\""
+	}
 }
 
 func (this *CharVal) GetElementType() ElementType {
@@ -32,29 +87,36 @@ func (this *CharVal) GetDependentRuleNames() Set_RuleName {
 }
 
 //@Override
-func (this *CharVal) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *CharVal) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
 //@Override
-func (this *CharVal) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule){
+func (this *CharVal) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext){
 	//若CharVal的内容为空，则创建一条从开始状态到接受状态的epsilon迁移。
 	if len(this.value) == 0 {
 		startState.AddTransitEpsilon(acceptingState)
 		return
 	}
 
+	//ABNF的字符串默认大小写不敏感，每个字母同时生成大写和小写两个迁移；
+	//%s前缀或NFA构造时要求大小写敏感，则只生成原字符的迁移
+	caseSensitive := this.IsCaseSensitive(context)
 	current := startState
 	buffer := []byte(this.value)
 	for j := 0; j < len(buffer); j++ {
 		//最后一个节点使用方法参数中的acceptingState，中间节点自行创建
+		next := acceptingState
 		if j < len(buffer)-1 {
-			current = current.AddTransitByte1(buffer[j])
+			next = automata.NewNFAState()
+		}
+		if caseSensitive {
+			current = current.AddTransitInt2(int(buffer[j]), next)
 		} else {
-			current = current.AddTransitByte2(buffer[j], acceptingState)
+			current = current.AddTransitByte2(buffer[j], next)
 		}
 	}
 	return
@@ -2,38 +2,45 @@ package abnf
 
 import (
 	"bytes"
-	"container/list"
 	"GoABNF/automata"
 )
 
 // concatenation  =  repetition *(1*c-wsp repetition)
 type Concatenation struct {
-	repetitions *list.List
+	Span
+	repetitions []*Repetition
 }
 
 func NewConcatenation() *Concatenation {
 	this := &Concatenation{}
-	this.repetitions = list.New()
+	this.repetitions = make([]*Repetition, 0)
 	return this
 }
 
 func (this *Concatenation) AddRepetition(repetition *Repetition) {
-	this.repetitions.PushBack(repetition)
+	this.repetitions = append(this.repetitions, repetition)
 }
 
-func (this *Concatenation) GetRepetitions() *list.List {
+func (this *Concatenation) GetRepetitions() []*Repetition {
 	return this.repetitions
 }
 
+func (this *Concatenation) SetRepetitions(repetitions []*Repetition) {
+	this.repetitions = repetitions
+}
+
+func (this *Concatenation) GetLastRepetition() *Repetition {
+	return this.repetitions[len(this.repetitions)-1]
+}
+
 func (this *Concatenation) String() string {
 	var s bytes.Buffer
 
-	for e := this.repetitions.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*Repetition)
-		s.WriteString(v.String())
-		if e.Next() != nil {
+	for i, v := range this.repetitions {
+		if i > 0 {
 			s.WriteString(" ")
 		}
+		s.WriteString(v.String())
 	}
 
 	return s.String()
@@ -41,32 +48,29 @@ func (this *Concatenation) String() string {
 
 func (this *Concatenation) GetDependentRuleNames() Set_RuleName {
 	ruleNames := make(Set_RuleName)
-	for e := this.repetitions.Front(); e != nil; e = e.Next() {
-		v := e.Value.(*Repetition)
+	for _, v := range this.repetitions {
 		s := v.GetDependentRuleNames()
 		for _, r := range s {
-			ruleNames[r.rulename] = r
+			ruleNames[r.GetKey()] = r
 		}
 	}
 	return ruleNames
 }
 
-func (this *Concatenation) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Concatenation) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Concatenation) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
+func (this *Concatenation) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
 	current := startState
-	e := this.repetitions.Front()
 	var next *automata.NFAState
-	for index := 0; index < this.repetitions.Len()-1; index++ {
+	for index := 0; index < len(this.repetitions)-1; index++ {
 		next = automata.NewNFAState()
-		e.Value.(*Repetition).GetNFAStates(current, next, rules)
+		this.repetitions[index].GetNFAStates(current, next, context)
 		current = next
-		e = e.Next()
 	}
-	e.Value.(*Repetition).GetNFAStates(current, acceptingState, rules)
+	this.repetitions[len(this.repetitions)-1].GetNFAStates(current, acceptingState, context)
 }
@@ -0,0 +1,88 @@
+package abnf
+
+import (
+	"strings"
+)
+
+//    RFC 5234 Appendix B.1 定义的核心规则
+const coreRules = "ALPHA          =  %x41-5A / %x61-7A   ; A-Z / a-z\r\n" +
+	"BIT            =  \"0\" / \"1\"\r\n" +
+	"CHAR           =  %x01-7F\r\n" +
+	"                       ; any 7-bit US-ASCII character,\r\n" +
+	"                       ;  excluding NUL\r\n" +
+	"CR             =  %x0D\r\n" +
+	"                       ; carriage return\r\n" +
+	"CRLF           =  CR LF\r\n" +
+	"                       ; Internet standard newline\r\n" +
+	"CTL            =  %x00-1F / %x7F\r\n" +
+	"                       ; controls\r\n" +
+	"DIGIT          =  %x30-39\r\n" +
+	"                       ; 0-9\r\n" +
+	"DQUOTE         =  %x22\r\n" +
+	"                       ; \" (Double Quote)\r\n" +
+	"HEXDIG         =  DIGIT / \"A\" / \"B\" / \"C\" / \"D\" / \"E\" / \"F\"\r\n" +
+	"HTAB           =  %x09\r\n" +
+	"                       ; horizontal tab\r\n" +
+	"LF             =  %x0A\r\n" +
+	"                       ; linefeed\r\n" +
+	"LWSP           =  *(WSP / CRLF WSP)\r\n" +
+	"                       ; Use of this linear-white-space rule\r\n" +
+	"                       ;  permits lines containing only white\r\n" +
+	"                       ;  space that are no longer legal in\r\n" +
+	"                       ;  mail headers and have caused\r\n" +
+	"                       ;  interoperability problems in other\r\n" +
+	"                       ;  contexts.\r\n" +
+	"                       ; Do not use when defining mail\r\n" +
+	"                       ;  headers and use with caution in\r\n" +
+	"                       ;  other contexts.\r\n" +
+	"OCTET          =  %x00-FF\r\n" +
+	"                       ; 8 bits of data\r\n" +
+	"SP             =  %x20\r\n" +
+	"VCHAR          =  %x21-7E\r\n" +
+	"                       ; visible (printing) characters\r\n" +
+	"WSP            =  SP / HTAB\r\n" +
+	"                       ; white space\r\n"
+
+//    返回RFC 5234 Appendix B.1 的核心规则（ALPHA、BIT、CHAR、CR、CRLF、CTL、DIGIT、DQUOTE、
+//    HEXDIG、HTAB、LF、LWSP、OCTET、SP、VCHAR、WSP）。
+//    每次调用都重新解析，返回的规则可以被修改而不影响其他Grammar
+func CoreRules() *Grammar {
+	parser := NewParser(strings.NewReader(coreRules))
+	parser.SetLineEnding(LINE_ENDING_CRLF)
+	grammar, err := parser.Parse()
+	if err != nil {
+		panic("Core rules can not be parsed: " + err.Error())
+	}
+	return grammar
+}
+
+//    判断规则名（大小写不敏感）是否是核心规则
+func IsCoreRule(name string) bool {
+	switch CanonicalRuleName(name) {
+	case "alpha", "bit", "char", "cr", "crlf", "ctl", "digit", "dquote",
+		"hexdig", "htab", "lf", "lwsp", "octet", "sp", "vchar", "wsp":
+		return true
+	}
+	return false
+}
+
+//    把被引用但没有定义的核心规则加入Grammar，核心规则之间的引用（例如CRLF引用CR和LF）也一并解析。
+//    Grammar自己定义的同名规则优先，不会被覆盖。返回加入的核心规则
+func (this *Grammar) ResolveCoreRules() []*Rule {
+	core := CoreRules()
+	added := make([]*Rule, 0)
+	//    加入的核心规则可能引用其他的核心规则，因此从Grammar已有的规则开始逐条检查，
+	//    按引用出现的顺序加入，使结果与规则的顺序一致
+	for index := 0; index < len(this.rules); index++ {
+		Inspect(this.rules[index].GetElements(), func(node Node) bool {
+			if ruleName, ok := node.(*RuleName); ok && this.Lookup(ruleName.GetKey()) == nil {
+				if rule := core.Lookup(ruleName.GetKey()); rule != nil {
+					this.AddRule(rule)
+					added = append(added, rule)
+				}
+			}
+			return true
+		})
+	}
+	return added
+}
@@ -0,0 +1,78 @@
+package abnf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsCoreRule(t *testing.T) {
+	for _, name := range []string{"ALPHA", "crlf", "Wsp", "OCTET", "LWSP"} {
+		if !IsCoreRule(name) {
+			t.Errorf("%s is not a core rule", name)
+		}
+	}
+	for _, name := range []string{"alphas", "", "rule", "CRLF2"} {
+		if IsCoreRule(name) {
+			t.Errorf("%q is a core rule", name)
+		}
+	}
+	core := CoreRules()
+	if len(core.GetRules()) != 16 {
+		t.Errorf("%d core rules, want 16", len(core.GetRules()))
+	}
+	for _, rule := range core.GetRules() {
+		if !IsCoreRule(rule.GetRuleName().String()) {
+			t.Errorf("%s is defined in CoreRules but is not a core rule", rule.GetRuleName())
+		}
+	}
+	if CoreRules().Lookup("ALPHA") == core.Lookup("ALPHA") {
+		t.Errorf("CoreRules returns shared rules")
+	}
+}
+
+func TestResolveCoreRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		added []string
+		//    解析之后每个规则名对应的定义
+		rules map[string]string
+	}{
+		{
+			"core rules",
+			"a = CRLF b / digit\nb = \"x\"\n",
+			[]string{"CRLF", "DIGIT", "CR", "LF"},
+			map[string]string{"crlf": "CRLF = CR LF", "cr": "CR = %x0D", "digit": "DIGIT = %x30-39"},
+		},
+		{
+			"user definition",
+			"a = DIGIT / CRLF\nDIGIT = \"0\" / \"1\"\nCR = %x0D.0A\n",
+			[]string{"CRLF", "LF"},
+			map[string]string{"digit": "DIGIT = \"0\"/\"1\"", "cr": "CR = %x0D.0A", "crlf": "CRLF = CR LF"},
+		},
+		{
+			"not core rules",
+			"a = zz / alphas\n",
+			[]string{},
+			map[string]string{"zz": "", "alphas": "", "alpha": ""},
+		},
+	}
+	for _, test := range tests {
+		grammar := NewGrammar2(parseRules(t, test.text))
+		if added := ruleNames(grammar.ResolveCoreRules()); !reflect.DeepEqual(added, test.added) {
+			t.Errorf("%s: added %v, want %v", test.name, added, test.added)
+		}
+		for name, want := range test.rules {
+			got := ""
+			if rule := grammar.Lookup(name); rule != nil {
+				got = rule.String()
+			}
+			if got != want {
+				t.Errorf("%s: %s is defined as %q, want %q", test.name, name, got, want)
+			}
+		}
+		if names := ruleNames(grammar.ResolveCoreRules()); len(names) != 0 {
+			t.Errorf("%s: resolving twice adds %v", test.name, names)
+		}
+	}
+}
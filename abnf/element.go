@@ -18,7 +18,10 @@ const (
 type Element interface{
 	String() string;
 	GetElementType() ElementType;
+	GetStart() Position
+	GetEnd() Position
+	SetSpan(start, end Position)
 	GetDependentRuleNames() Set_RuleName
-	GetNFA(rules map[string]*Rule) *automata.NFA
-    GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule)
+	GetNFA(context *NFAContext) *automata.NFA
+    GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext)
 };
\ No newline at end of file
@@ -6,6 +6,7 @@ import (
 
 // elements       =  alternation *c-wsp
 type Elements struct {
+	Span
 	alternation *Alternation
 }
 
@@ -27,13 +28,13 @@ func (this *Elements) GetDependentRuleNames() Set_RuleName {
     return this.alternation.GetDependentRuleNames();
 }
 
-func (this *Elements) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Elements) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Elements) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
-	this.alternation.GetNFAStates(startState, acceptingState, rules);
+func (this *Elements) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
+	this.alternation.GetNFAStates(startState, acceptingState, context);
 }
\ No newline at end of file
@@ -1,47 +1,105 @@
 package abnf
 
 import (
+	"bytes"
 	"fmt"
-	"strconv"
 )
 
+//    语法错误：在Line行Pos列读到了Actual，而期待的是Expected
 type MatchException struct { //extends Exception {
-	actual   int
-	pos      int
-	line     int
-	expected string
+	Actual   int
+	Pos      int
+	Line     int
+	Expected string
+	//    出错的文法文件，未知时为空字符串
+	File string
 }
 
 func NewMatchException(expected string, actual, pos, line int) *MatchException {
 	this := &MatchException{}
-	this.expected = expected
-	this.actual = actual
-	this.pos = pos
-	this.line = line
+	this.Expected = expected
+	this.Actual = actual
+	this.Pos = pos
+	this.Line = line
 	return this
 }
 
 func (this *MatchException) String() string {
-	return "Mismatch with '" + string(byte(this.actual)) +
-		"' [" + fmt.Sprintf("%02X", this.actual) + "] at position " +
-		strconv.Itoa(this.pos) + ": line " + strconv.Itoa(this.line) +
-		". Expected value is " + this.expected
+	return fileOf(this.File) + "Mismatch with " + charOf(this.Actual) + " at " +
+		Position{Line: this.Line, Column: this.Pos}.String() + ". Expected value is " + this.Expected
 }
 
+func (this *MatchException) Error() string {
+	return this.String()
+}
+
+//    规则定义冲突，例如同一规则用"="定义了两次
 type CollisionException struct { //extends Exception {
-	collision string
-	pos       int
-	line      int
+	Collision string
+	Pos       int
+	Line      int
+	//    发生冲突的文法文件，未知时为空字符串
+	File string
 }
 
 func NewCollisionException(collision string, pos, line int) *CollisionException {
 	this := &CollisionException{}
-	this.collision = collision
-	this.pos = pos
-	this.line = line
+	this.Collision = collision
+	this.Pos = pos
+	this.Line = line
 	return this
 }
 
 func (this *CollisionException) String() string {
-	return "Collision at position " + strconv.Itoa(this.pos) + ": line " + strconv.Itoa(this.line) + ". Description: " + this.collision
+	return fileOf(this.File) + "Collision at " + Position{Line: this.Line, Column: this.Pos}.String() + ". Description: " + this.Collision
+}
+
+func (this *CollisionException) Error() string {
+	return this.String()
+}
+
+//    错误信息中的字符：可显示的字符写成'c' [%xNN]，其他字符只写%xNN，输入结束写成end of input
+func charOf(value int) string {
+	if value == PEEKER_EOF {
+		return "end of input"
+	}
+	if value >= 0x20 && value <= 0x7E {
+		return "'" + string(rune(value)) + "' [" + fmt.Sprintf("%%x%02X", value) + "]"
+	}
+	return fmt.Sprintf("%%x%02X", value)
+}
+
+//    错误信息中的字符范围，与charOf一样，可显示的范围写成'a'-'z' [%xNN-NN]，其他范围只写%xNN-NN
+func rangeOf(lower, upper int) string {
+	if lower >= 0x20 && upper <= 0x7E {
+		return "'" + string(rune(lower)) + "'-'" + string(rune(upper)) + "' [" + fmt.Sprintf("%%x%02X-%02X", lower, upper) + "]"
+	}
+	return fmt.Sprintf("%%x%02X-%02X", lower, upper)
+}
+
+//    错误信息中的文件名前缀
+func fileOf(file string) string {
+	if file == "" {
+		return ""
+	}
+	return file + ": "
+}
+
+//    解析过程中发现的所有错误，按出现的顺序排列
+type ErrorList []error
+
+func (this ErrorList) Error() string {
+	var s bytes.Buffer
+	for i, err := range this {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(err.Error())
+	}
+	return s.String()
+}
+
+//    使errors.Is和errors.As可以找到列表中的每个错误
+func (this ErrorList) Unwrap() []error {
+	return this
 }
@@ -0,0 +1,54 @@
+package abnf
+
+//    一组ABNF规则，保持规则在源文件中的定义顺序，并可以按规则名查找。
+//    规则名大小写不敏感（RFC 5234 2.1），查找时使用规范形式
+type Grammar struct {
+	rules   []*Rule
+	ruleMap map[string]*Rule
+}
+
+func NewGrammar() *Grammar {
+	this := &Grammar{}
+	this.rules = make([]*Rule, 0)
+	this.ruleMap = make(map[string]*Rule)
+	return this
+}
+
+//    用一组规则构造Grammar，同名的规则只保留第一条
+func NewGrammar2(rules []*Rule) *Grammar {
+	this := NewGrammar()
+	for _, rule := range rules {
+		this.AddRule(rule)
+	}
+	return this
+}
+
+//    加入一条规则，如果已经有同名的规则则不加入并返回false
+func (this *Grammar) AddRule(rule *Rule) bool {
+	key := rule.GetRuleName().GetKey()
+	if _, present := this.ruleMap[key]; present {
+		return false
+	}
+	this.rules = append(this.rules, rule)
+	this.ruleMap[key] = rule
+	return true
+}
+
+//    按规则名（大小写不敏感）查找规则，没有定义时返回nil
+func (this *Grammar) Lookup(name string) *Rule {
+	return this.ruleMap[CanonicalRuleName(name)]
+}
+
+//    按定义顺序返回所有规则
+func (this *Grammar) GetRules() []*Rule {
+	return this.rules
+}
+
+//    以规范形式的规则名为键的规则表，可以直接用于NewNFAContext
+func (this *Grammar) GetRuleMap() map[string]*Rule {
+	return this.ruleMap
+}
+
+func (this *Grammar) Len() int {
+	return len(this.rules)
+}
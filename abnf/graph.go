@@ -0,0 +1,207 @@
+package abnf
+
+import (
+	"sort"
+)
+
+//    规则之间的依赖图：规则A的定义中引用了规则B，则有一条A到B的边。
+//    图中的顶点是规则名的规范形式（见CanonicalRuleName），被引用但没有定义的规则名也是顶点。
+//    构造时用Tarjan算法求出强连通分量，一个强连通分量中的规则互相（间接）引用
+type DependencyGraph struct {
+	grammar *Grammar
+
+	//    每条规则直接依赖的规则名，按规范形式排序
+	dependencies map[string][]string
+
+	//    被引用但没有定义的规则名
+	undefined Set_RuleName
+
+	//    强连通分量，按逆拓扑序排列，即被依赖的分量排在依赖它的分量之前
+	components [][]*Rule
+
+	//    每条规则所在的强连通分量在components中的下标
+	componentOf map[string]int
+
+	//    每条规则在Grammar中的定义顺序
+	orders map[*Rule]int
+}
+
+func NewDependencyGraph(grammar *Grammar) *DependencyGraph {
+	this := &DependencyGraph{}
+	this.grammar = grammar
+	this.dependencies = make(map[string][]string)
+	this.undefined = make(Set_RuleName)
+	this.components = make([][]*Rule, 0)
+	this.componentOf = make(map[string]int)
+	this.orders = make(map[*Rule]int)
+
+	for i, rule := range grammar.GetRules() {
+		this.orders[rule] = i
+		dependent := rule.GetElements().GetDependentRuleNames()
+		keys := make([]string, 0, len(dependent))
+		for key, ruleName := range dependent {
+			keys = append(keys, key)
+			if grammar.Lookup(key) == nil {
+				this.undefined[key] = ruleName
+			}
+		}
+		sort.Strings(keys)
+		this.dependencies[rule.GetRuleName().GetKey()] = keys
+	}
+	this.tarjan()
+	return this
+}
+
+//    Tarjan强连通分量算法，分量按完成的顺序加入，即逆拓扑序
+func (this *DependencyGraph) tarjan() {
+	index := 0
+	indexes := make(map[string]int)
+	lowlinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+
+	var connect func(key string)
+	connect = func(key string) {
+		indexes[key] = index
+		lowlinks[key] = index
+		index++
+		stack = append(stack, key)
+		onStack[key] = true
+
+		for _, next := range this.dependencies[key] {
+			//    没有定义的规则名不参与强连通分量
+			if this.grammar.Lookup(next) == nil {
+				continue
+			}
+			if _, visited := indexes[next]; !visited {
+				connect(next)
+				lowlinks[key] = min(lowlinks[key], lowlinks[next])
+			} else if onStack[next] {
+				lowlinks[key] = min(lowlinks[key], indexes[next])
+			}
+		}
+
+		if lowlinks[key] == indexes[key] {
+			component := make([]*Rule, 0)
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				this.componentOf[top] = len(this.components)
+				component = append(component, this.grammar.Lookup(top))
+				if top == key {
+					break
+				}
+			}
+			//    分量中的规则按定义顺序排列
+			sort.Slice(component, func(i, j int) bool {
+				return this.orders[component[i]] < this.orders[component[j]]
+			})
+			this.components = append(this.components, component)
+		}
+	}
+
+	for _, rule := range this.grammar.GetRules() {
+		if _, visited := indexes[rule.GetRuleName().GetKey()]; !visited {
+			connect(rule.GetRuleName().GetKey())
+		}
+	}
+}
+
+func (this *DependencyGraph) GetGrammar() *Grammar {
+	return this.grammar
+}
+
+//    返回规则直接依赖的规则名（规范形式），包括没有定义的规则名
+func (this *DependencyGraph) GetDependencies(name string) []string {
+	return this.dependencies[CanonicalRuleName(name)]
+}
+
+//    返回被引用但没有定义的规则名
+func (this *DependencyGraph) GetUndefinedRuleNames() Set_RuleName {
+	return this.undefined
+}
+
+//    返回所有强连通分量，被依赖的分量排在依赖它的分量之前
+func (this *DependencyGraph) GetComponents() [][]*Rule {
+	return this.components
+}
+
+//    返回规则所在的强连通分量，规则没有定义时返回nil
+func (this *DependencyGraph) GetComponent(name string) []*Rule {
+	index, present := this.componentOf[CanonicalRuleName(name)]
+	if !present {
+		return nil
+	}
+	return this.components[index]
+}
+
+//    规则直接引用了自己
+func (this *DependencyGraph) IsSelfRecursive(name string) bool {
+	key := CanonicalRuleName(name)
+	for _, next := range this.dependencies[key] {
+		if next == key {
+			return true
+		}
+	}
+	return false
+}
+
+//    规则通过其他规则间接引用了自己，即所在的强连通分量中有不止一条规则
+func (this *DependencyGraph) IsMutuallyRecursive(name string) bool {
+	return len(this.GetComponent(name)) > 1
+}
+
+//    规则直接或间接引用了自己
+func (this *DependencyGraph) IsRecursive(name string) bool {
+	return this.IsSelfRecursive(name) || this.IsMutuallyRecursive(name)
+}
+
+//    返回从规则start出发（直接或间接）引用到的所有规则，包括start本身，
+//    按依赖顺序排列：被依赖的规则排在依赖它的规则之前，同一强连通分量中的规则按定义顺序排列。
+//    start没有定义时返回空列表
+func (this *DependencyGraph) GetReachableRules(start string) []*Rule {
+	reachable := this.reachable(start)
+	rules := make([]*Rule, 0, len(reachable))
+	for _, component := range this.components {
+		for _, rule := range component {
+			if reachable[rule.GetRuleName().GetKey()] {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+//    返回从规则start出发引用不到的规则，按定义顺序排列
+func (this *DependencyGraph) GetUnreachableRules(start string) []*Rule {
+	reachable := this.reachable(start)
+	rules := make([]*Rule, 0)
+	for _, rule := range this.grammar.GetRules() {
+		if !reachable[rule.GetRuleName().GetKey()] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+//    返回只包含从规则start出发引用到的规则的Grammar（闭包），规则按依赖顺序排列
+func (this *DependencyGraph) Prune(start string) *Grammar {
+	return NewGrammar2(this.GetReachableRules(start))
+}
+
+//    从start出发可以到达的已定义规则（规范形式）
+func (this *DependencyGraph) reachable(start string) map[string]bool {
+	reachable := make(map[string]bool)
+	stack := []string{CanonicalRuleName(start)}
+	for len(stack) > 0 {
+		key := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reachable[key] || this.grammar.Lookup(key) == nil {
+			continue
+		}
+		reachable[key] = true
+		stack = append(stack, this.dependencies[key]...)
+	}
+	return reachable
+}
@@ -0,0 +1,91 @@
+package abnf
+
+import (
+	"reflect"
+	"testing"
+)
+
+//    规则列表中的规则名
+func ruleNames(rules []*Rule) []string {
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.GetRuleName().String())
+	}
+	return names
+}
+
+func TestDependencyGraph(t *testing.T) {
+	graph := NewDependencyGraph(NewGrammar2(parseRules(t,
+		"s = a c\n"+
+			"a = b \"x\"\n"+
+			"b = a / \"y\"\n"+
+			"c = c \"z\" / d\n"+
+			"d = \"d\"\n"+
+			"u = s zz\n"+
+			"v = \"v\"\n")))
+
+	components := make([][]string, 0)
+	for _, component := range graph.GetComponents() {
+		components = append(components, ruleNames(component))
+	}
+	want := [][]string{{"a", "b"}, {"d"}, {"c"}, {"s"}, {"u"}, {"v"}}
+	if !reflect.DeepEqual(components, want) {
+		t.Errorf("components = %v, want %v", components, want)
+	}
+	//    被依赖的分量排在依赖它的分量之前
+	for i, component := range graph.GetComponents() {
+		for _, rule := range component {
+			for _, dependency := range graph.GetDependencies(rule.GetRuleName().GetKey()) {
+				if index, present := graph.componentOf[dependency]; present && index > i {
+					t.Errorf("%s depends on %s in a later component", rule.GetRuleName(), dependency)
+				}
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(graph.GetDependencies("U"), []string{"s", "zz"}) {
+		t.Errorf("dependencies of u = %v", graph.GetDependencies("U"))
+	}
+	if _, present := graph.GetUndefinedRuleNames()["zz"]; !present || len(graph.GetUndefinedRuleNames()) != 1 {
+		t.Errorf("undefined = %v", graph.GetUndefinedRuleNames())
+	}
+	if graph.GetComponent("zz") != nil {
+		t.Errorf("undefined rule zz has a component")
+	}
+
+	recursion := []struct {
+		name     string
+		self     bool
+		mutually bool
+	}{
+		{"a", false, true},
+		{"b", false, true},
+		{"c", true, false},
+		{"d", false, false},
+		{"s", false, false},
+	}
+	for _, test := range recursion {
+		if graph.IsSelfRecursive(test.name) != test.self || graph.IsMutuallyRecursive(test.name) != test.mutually ||
+			graph.IsRecursive(test.name) != (test.self || test.mutually) {
+			t.Errorf("%s: self = %v, mutually = %v", test.name, graph.IsSelfRecursive(test.name), graph.IsMutuallyRecursive(test.name))
+		}
+	}
+
+	if names := ruleNames(graph.GetReachableRules("S")); !reflect.DeepEqual(names, []string{"a", "b", "d", "c", "s"}) {
+		t.Errorf("reachable = %v", names)
+	}
+	if names := ruleNames(graph.GetUnreachableRules("s")); !reflect.DeepEqual(names, []string{"u", "v"}) {
+		t.Errorf("unreachable = %v", names)
+	}
+	if names := ruleNames(graph.GetReachableRules("zz")); len(names) != 0 {
+		t.Errorf("reachable from undefined rule = %v", names)
+	}
+
+	pruned := graph.Prune("s")
+	if names := ruleNames(pruned.GetRules()); !reflect.DeepEqual(names, []string{"a", "b", "d", "c", "s"}) {
+		t.Errorf("pruned = %v", names)
+	}
+	if pruned.Lookup("u") != nil || pruned.Lookup("c") == nil {
+		t.Errorf("pruned grammar looks up the wrong rules")
+	}
+}
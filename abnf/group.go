@@ -7,6 +7,8 @@ import (
 //  group          =  "(" *c-wsp alternation *c-wsp ")"
 
 type Group struct { //implements Element {
+	Span
+	Trivia
 	alternation *Alternation
 }
 
@@ -16,6 +18,10 @@ func NewGroup(alternation *Alternation) *Group {
 	return this
 }
 
+func (this *Group) GetAlternation() *Alternation {
+	return this.alternation
+}
+
 func (this *Group) String() string{
 	return "("+this.alternation.String()+")"
 }
@@ -28,13 +34,13 @@ func (this *Group) GetDependentRuleNames() Set_RuleName {
 	return this.alternation.GetDependentRuleNames();
 }
 
-func (this *Group) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Group) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Group) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
-	this.alternation.GetNFAStates(startState, acceptingState, rules);
+func (this *Group) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
+	this.alternation.GetNFAStates(startState, acceptingState, context);
 }
\ No newline at end of file
@@ -0,0 +1,179 @@
+package abnf
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//    加载器中的一个文法来源
+type source struct {
+	name   string
+	reader io.Reader
+	prefix string
+}
+
+//    把多个文法文件合并成一个Grammar，例如分别来自RFC 3261、RFC 3966、RFC 4566的规则。
+//    每个文件可以有自己的规则名前缀（例如"RFC3261-"），前缀加在该文件中定义和引用的每个规则名之前。
+//    文件中的引用按以下顺序解析：
+//        1. 同一文件中定义的规则（加上本文件的前缀）
+//        2. 按原样书写的规则名，即已经写明前缀的引用，例如RFC 3261中引用"RFC3966-telephone-subscriber"
+//        3. 唯一一个其他文件中定义的同名规则（加上那个文件的前缀）
+//        4. 打开了核心规则选项时，没有任何文件定义的核心规则（ALPHA、DIGIT等）
+//    都找不到的引用保持本文件的前缀，作为未定义的规则留给分析器报告
+type Loader struct {
+	sources []*source
+
+	//    行结束符的处理方式
+	lineEnding LineEnding
+
+	//    是否自动加入被引用但没有定义的RFC 5234核心规则
+	coreRules bool
+}
+
+func NewLoader() *Loader {
+	this := &Loader{}
+	this.sources = make([]*source, 0)
+	this.lineEnding = LINE_ENDING_LENIENT
+	return this
+}
+
+//    加入一个文法文件，文件在Load时才打开
+func (this *Loader) AddFile(fileName, prefix string) {
+	this.sources = append(this.sources, &source{fileName, nil, prefix})
+}
+
+//    加入一个文法输入源，name用于错误信息
+func (this *Loader) AddReader(name string, reader io.Reader, prefix string) {
+	this.sources = append(this.sources, &source{name, reader, prefix})
+}
+
+func (this *Loader) SetLineEnding(lineEnding LineEnding) {
+	this.lineEnding = lineEnding
+}
+
+func (this *Loader) SetCoreRules(coreRules bool) {
+	this.coreRules = coreRules
+}
+
+//    解析所有文件并合并为一个Grammar，规则按文件加入的顺序排列。
+//    与Parser.Parse一样，出错时仍然返回成功解析的规则，error是包含所有错误的ErrorList；
+//    不同文件中定义了同名的规则时，记录带有文件名的CollisionException，保留先加入的文件中的定义
+func (this *Loader) Load() (*Grammar, error) {
+	errs := make(ErrorList, 0)
+	grammar := NewGrammar()
+	//    每条规则来自哪个文件
+	owners := make(map[*Rule]*source)
+	//    每个文件中定义的规则，键值是去掉前缀的规范形式的规则名
+	locals := make(map[*source]map[string]*Rule)
+
+	for _, source := range this.sources {
+		rules, err := this.parse(source)
+		if err != nil {
+			if list, ok := err.(ErrorList); ok {
+				errs = append(errs, list...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+		if rules == nil {
+			continue
+		}
+		locals[source] = make(map[string]*Rule)
+		for _, rule := range rules.GetRules() {
+			name := rule.GetRuleName().String()
+			if defined := grammar.Lookup(name); defined != nil {
+				start := rule.GetRuleName().GetStart()
+				collision := NewCollisionException(name+" is redefined (first defined in "+owners[defined].name+" at line "+strconv.Itoa(defined.GetRuleName().GetStart().Line)+").", start.Column, start.Line)
+				collision.File = source.name
+				errs = append(errs, collision)
+				continue
+			}
+			//    只记录被加入Grammar的规则，冲突中被丢弃的定义不参与跨文件引用的解析
+			grammar.AddRule(rule)
+			owners[rule] = source
+			locals[source][CanonicalRuleName(name[len(source.prefix):])] = rule
+		}
+	}
+
+	//    解析跨文件的引用
+	for _, rule := range grammar.GetRules() {
+		owner := owners[rule]
+		Inspect(rule.GetElements(), func(node Node) bool {
+			if ruleName, ok := node.(*RuleName); ok {
+				if err := this.resolve(ruleName, owner, grammar, locals); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return true
+		})
+	}
+
+	if this.coreRules {
+		grammar.ResolveCoreRules()
+	}
+	if len(errs) > 0 {
+		return grammar, errs
+	}
+	return grammar, nil
+}
+
+func (this *Loader) parse(source *source) (*Grammar, error) {
+	reader := source.reader
+	if reader == nil {
+		f, err := os.Open(source.name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+	parser := NewParser(reader)
+	parser.SetPrefix(source.prefix)
+	parser.SetFileName(source.name)
+	parser.SetLineEnding(this.lineEnding)
+	return parser.Parse()
+}
+
+//    按Loader说明中的顺序解析一个引用，找到定义时把引用改为定义的规则名。
+//    多个其他文件都定义了被引用的规则时无法确定引用的是哪一个，返回列出这些文件的CollisionException
+func (this *Loader) resolve(ruleName *RuleName, owner *source, grammar *Grammar, locals map[*source]map[string]*Rule) error {
+	//    1. 同一文件中的定义，Parser已经加上了本文件的前缀
+	if grammar.Lookup(ruleName.String()) != nil {
+		return nil
+	}
+	written := ruleName.String()[len(owner.prefix):]
+	//    2. 已经写明前缀的引用
+	if grammar.Lookup(written) != nil {
+		ruleName.SetRuleName(written)
+		return nil
+	}
+	//    3. 唯一一个其他文件中的定义
+	found := make([]*Rule, 0)
+	files := make([]string, 0)
+	for _, source := range this.sources {
+		if source == owner {
+			continue
+		}
+		if rule, present := locals[source][CanonicalRuleName(written)]; present {
+			found = append(found, rule)
+			files = append(files, source.name)
+		}
+	}
+	if len(found) == 1 {
+		ruleName.SetRuleName(found[0].GetRuleName().String())
+		return nil
+	}
+	if len(found) > 1 {
+		start := ruleName.GetStart()
+		collision := NewCollisionException("The reference to "+written+" is ambiguous: it is defined in "+strings.Join(files, ", ")+".", start.Column, start.Line)
+		collision.File = owner.name
+		return collision
+	}
+	//    4. 没有任何文件定义的核心规则，去掉前缀，由ResolveCoreRules加入
+	if this.coreRules && IsCoreRule(written) {
+		ruleName.SetRuleName(written)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package abnf
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type loaderSource struct {
+	name   string
+	prefix string
+	text   string
+}
+
+//    规则中第一个引用的规则名
+func firstReference(rule *Rule) string {
+	reference := ""
+	Inspect(rule.GetElements(), func(node Node) bool {
+		if ruleName, ok := node.(*RuleName); ok && reference == "" {
+			reference = ruleName.String()
+		}
+		return reference == ""
+	})
+	return reference
+}
+
+func TestLoaderResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    []loaderSource
+		coreRules  bool
+		references map[string]string
+		errors     []string
+	}{
+		{
+			"same file",
+			[]loaderSource{{"a.abnf", "A-", "x = y\ny = \"1\"\n"}, {"b.abnf", "B-", "y = \"2\"\n"}},
+			false,
+			map[string]string{"A-x": "A-y"},
+			nil,
+		},
+		{
+			"explicit prefix",
+			[]loaderSource{{"a.abnf", "A-", "y = \"1\"\n"}, {"b.abnf", "B-", "z = A-y\ny = \"2\"\n"}},
+			false,
+			map[string]string{"B-z": "A-y"},
+			nil,
+		},
+		{
+			"unique other file",
+			[]loaderSource{{"a.abnf", "A-", "y = \"1\"\n"}, {"b.abnf", "B-", "w = y\n"}},
+			false,
+			map[string]string{"B-w": "A-y"},
+			nil,
+		},
+		{
+			"core rule",
+			[]loaderSource{{"a.abnf", "A-", "v = DIGIT\n"}},
+			true,
+			map[string]string{"A-v": "DIGIT"},
+			nil,
+		},
+		{
+			"core rule disabled",
+			[]loaderSource{{"a.abnf", "A-", "v = DIGIT\n"}},
+			false,
+			map[string]string{"A-v": "A-DIGIT"},
+			nil,
+		},
+		{
+			"undefined",
+			[]loaderSource{{"a.abnf", "A-", "y = \"1\"\n"}, {"b.abnf", "B-", "u = zz\n"}},
+			false,
+			map[string]string{"B-u": "B-zz"},
+			nil,
+		},
+		{
+			"ambiguous",
+			[]loaderSource{{"a.abnf", "A-", "k = \"1\"\n"}, {"b.abnf", "B-", "k = \"2\"\n"}, {"c.abnf", "C-", "r = \"0\" k\n"}},
+			false,
+			map[string]string{"C-r": "C-k"},
+			[]string{"c.abnf: Collision at 1:9. Description: The reference to k is ambiguous: it is defined in a.abnf, b.abnf."},
+		},
+		{
+			"collision",
+			[]loaderSource{{"a.abnf", "P-", "t = \"1\"\n"}, {"b.abnf", "P-", "s = \"0\"\nt = \"2\"\n"}, {"c.abnf", "C-", "r = t\n"}},
+			false,
+			//    b.abnf中被丢弃的t不会使c.abnf中的引用变得有歧义
+			map[string]string{"C-r": "P-t"},
+			[]string{"b.abnf: Collision at 2:1. Description: P-t is redefined (first defined in a.abnf at line 1)."},
+		},
+	}
+	for _, test := range tests {
+		loader := NewLoader()
+		for _, source := range test.sources {
+			loader.AddReader(source.name, strings.NewReader(source.text), source.prefix)
+		}
+		loader.SetCoreRules(test.coreRules)
+		grammar, err := loader.Load()
+
+		messages := make([]string, 0)
+		if err != nil {
+			for _, e := range err.(ErrorList) {
+				messages = append(messages, e.Error())
+			}
+		}
+		if len(messages) != len(test.errors) || (len(messages) > 0 && !reflect.DeepEqual(messages, test.errors)) {
+			t.Errorf("%s: errors = %q, want %q", test.name, messages, test.errors)
+		}
+		for name, want := range test.references {
+			rule := grammar.Lookup(name)
+			if rule == nil {
+				t.Errorf("%s: rule %s is not loaded", test.name, name)
+				continue
+			}
+			if reference := firstReference(rule); reference != want {
+				t.Errorf("%s: reference in %s resolved to %s, want %s", test.name, name, reference, want)
+			}
+		}
+		if test.coreRules && grammar.Lookup("DIGIT") == nil {
+			t.Errorf("%s: core rule DIGIT is not added", test.name)
+		}
+	}
+}
+
+func TestLoaderMissingFile(t *testing.T) {
+	loader := NewLoader()
+	loader.AddFile("no-such-file.abnf", "")
+	loader.AddReader("a.abnf", strings.NewReader("a = \"x\"\n"), "")
+	grammar, err := loader.Load()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want a not-exist error", err)
+	}
+	if grammar.Lookup("a") == nil {
+		t.Errorf("rules of the other sources are not loaded")
+	}
+}
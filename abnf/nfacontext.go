@@ -0,0 +1,169 @@
+package abnf
+
+//    不允许展开递归的规则，遇到递归的引用时抛出异常
+const NFA_NO_RECURSION = -1
+
+//    NFA构造的上下文，在GetNFAStates的递归调用中逐层传递，
+//    包括规则名到规则定义的映射，以及构造NFA时的选项
+type NFAContext struct {
+	//    键值是规则名的规范形式
+	rules map[string]*Rule
+
+	//    是否强制字符串（char-val）大小写敏感，ABNF默认大小写不敏感
+	caseSensitive bool
+
+	//    递归规则最多展开的嵌套深度，超过深度的递归引用不生成任何迁移，
+	//    得到的NFA只接受原语言的一个子集（欠近似）
+	maxDepth int
+
+	//    正在展开的每条规则的嵌套层数
+	depths map[string]int
+
+	//    因为超过深度而被截断的规则，按第一次截断的顺序排列
+	truncated []string
+
+	//    截断的总次数
+	truncations int
+
+	//    无法用有限深度展开处理的规则，以及原因
+	unhandled map[string]string
+
+	//    unhandled中规则名的顺序
+	unhandledOrder []string
+
+	//    已经判断过的规则是否（直接或间接）引用自己
+	recursive map[string]bool
+}
+
+func NewNFAContext(rules map[string]*Rule) *NFAContext {
+	this := &NFAContext{}
+	this.rules = make(map[string]*Rule)
+	for rulename, rule := range rules {
+		this.rules[CanonicalRuleName(rulename)] = rule
+	}
+	this.caseSensitive = false
+	this.maxDepth = NFA_NO_RECURSION
+	this.depths = make(map[string]int)
+	this.truncated = make([]string, 0)
+	this.unhandled = make(map[string]string)
+	this.unhandledOrder = make([]string, 0)
+	this.recursive = make(map[string]bool)
+	return this
+}
+
+func (this *NFAContext) GetRules() map[string]*Rule {
+	return this.rules
+}
+
+//    按规则名查找规则定义（大小写不敏感），没有定义时返回nil
+func (this *NFAContext) GetRule(rulename string) *Rule {
+	return this.rules[CanonicalRuleName(rulename)]
+}
+
+func (this *NFAContext) IsCaseSensitive() bool {
+	return this.caseSensitive
+}
+
+func (this *NFAContext) SetCaseSensitive(caseSensitive bool) {
+	this.caseSensitive = caseSensitive
+}
+
+//    设置递归规则最多展开的嵌套深度，0表示递归的引用一次也不展开，
+//    NFA_NO_RECURSION（默认）表示遇到递归的引用时抛出异常。
+//    深度为n时规则自身最多嵌套n+1层，例如p = "(" p ")" / "x"最多接受n层括号。
+//    每展开一层都会复制一遍规则的定义：规则中有b处递归引用时状态数约为b^(n+1)；
+//    互相递归的k条规则各自计算深度，引用链最长为k*(n+1)，状态数最坏约为b^(k*(n+1))，
+//    因此对较大的递归分量应当只使用很小的深度
+func (this *NFAContext) SetMaxDepth(maxDepth int) {
+	this.maxDepth = maxDepth
+}
+
+func (this *NFAContext) GetMaxDepth() int {
+	return this.maxDepth
+}
+
+//    开始展开一条规则，返回false表示已经达到最大深度，这个引用不再展开
+func (this *NFAContext) enter(key string) bool {
+	if this.depths[key] > 0 {
+		if this.maxDepth == NFA_NO_RECURSION {
+			panic("Can not generate NFA for recursive rule " + key + " without a maximum recursion depth.")
+		}
+		if this.depths[key] > this.maxDepth {
+			this.truncations++
+			if !this.IsTruncated(key) {
+				this.truncated = append(this.truncated, key)
+			}
+			return false
+		}
+	}
+	this.depths[key]++
+	return true
+}
+
+//    结束展开一条规则
+func (this *NFAContext) leave(key string) {
+	this.depths[key]--
+}
+
+//    递归的规则在展开到最大深度之后是否被截断过
+func (this *NFAContext) IsTruncated(key string) bool {
+	for _, truncated := range this.truncated {
+		if truncated == CanonicalRuleName(key) {
+			return true
+		}
+	}
+	return false
+}
+
+//    返回因为超过最大深度而被截断的规则名（规范形式），这些规则生成的NFA是欠近似的
+func (this *NFAContext) GetTruncatedRules() []string {
+	return this.truncated
+}
+
+//    判断规则是否直接或间接引用自己
+func (this *NFAContext) IsRecursive(key string) bool {
+	key = CanonicalRuleName(key)
+	if recursive, present := this.recursive[key]; present {
+		return recursive
+	}
+	visited := make(map[string]bool)
+	var reaches func(from string) bool
+	reaches = func(from string) bool {
+		rule := this.rules[from]
+		if rule == nil {
+			return false
+		}
+		for next := range rule.GetElements().GetDependentRuleNames() {
+			if next == key {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if reaches(next) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	this.recursive[key] = reaches(key)
+	return this.recursive[key]
+}
+
+//    记录一条无法处理的规则
+func (this *NFAContext) addUnhandled(key, reason string) {
+	if _, present := this.unhandled[key]; !present {
+		this.unhandledOrder = append(this.unhandledOrder, key)
+	}
+	this.unhandled[key] = reason
+}
+
+//    返回无法用有限深度展开处理的规则名（规范形式）
+func (this *NFAContext) GetUnhandledRules() []string {
+	return this.unhandledOrder
+}
+
+//    返回规则无法处理的原因
+func (this *NFAContext) GetUnhandledReason(key string) string {
+	return this.unhandled[CanonicalRuleName(key)]
+}
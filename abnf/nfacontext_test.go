@@ -0,0 +1,97 @@
+package abnf
+
+import (
+	"GoABNF/automata"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func unrolledNFA(t *testing.T, text, start string, maxDepth int) (*automata.NFA, *NFAContext) {
+	grammar, err := NewParser(strings.NewReader(strings.Replace(text, "\n", "\r\n", -1))).Parse()
+	if err != nil {
+		t.Fatalf("%q: %v", text, err)
+	}
+	context := NewNFAContext(grammar.GetRuleMap())
+	context.SetMaxDepth(maxDepth)
+	startState := automata.NewNFAState()
+	acceptingState := automata.NewNFAState()
+	NewRuleName(start).GetNFAStates(startState, acceptingState, context)
+	return automata.NewNFA2(startState, acceptingState), context
+}
+
+//    n层括号嵌套的输入
+func nested(open, inner, close string, n int) string {
+	return strings.Repeat(open, n) + inner + strings.Repeat(close, n)
+}
+
+func TestUnrollDepth(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		start     string
+		truncated []string
+		unhandled []string
+	}{
+		{"self-embedding", "p = \"(\" p \")\" / \"x\"\n", "p", []string{"p"}, []string{}},
+		//    最内层的b中a被截断，b本身不匹配任何输入
+		{"mutual", "a = \"(\" b / \"x\"\nb = a \")\"\n", "a", []string{"a"}, []string{"b"}},
+	}
+	for _, test := range tests {
+		for maxDepth := 0; maxDepth <= 3; maxDepth++ {
+			nfa, context := unrolledNFA(t, test.text, test.start, maxDepth)
+			//    递归的引用最多展开maxDepth次，即最多接受maxDepth层嵌套
+			for n := 0; n <= maxDepth; n++ {
+				if input := nested("(", "x", ")", n); !nfa.Match([]byte(input)) {
+					t.Errorf("%s, depth %d: %s is not accepted", test.name, maxDepth, input)
+				}
+			}
+			if input := nested("(", "x", ")", maxDepth+1); nfa.Match([]byte(input)) {
+				t.Errorf("%s, depth %d: %s is accepted", test.name, maxDepth, input)
+			}
+			if !reflect.DeepEqual(context.GetTruncatedRules(), test.truncated) {
+				t.Errorf("%s, depth %d: truncated %v", test.name, maxDepth, context.GetTruncatedRules())
+			}
+			if !reflect.DeepEqual(context.GetUnhandledRules(), test.unhandled) {
+				t.Errorf("%s, depth %d: unhandled %v", test.name, maxDepth, context.GetUnhandledRules())
+			}
+		}
+	}
+}
+
+func TestUnrollReports(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		start     string
+		truncated []string
+		unhandled []string
+	}{
+		{"not recursive", "a = \"x\" b\nb = \"y\"\n", "a", []string{}, []string{}},
+		{"infinite", "r = \"x\" r\n", "r", []string{"r"},
+			[]string{"r matches no input when its recursion is unrolled to depth 2."}},
+		{"undefined", "a = \"x\" / b\n", "a", []string{}, []string{"b is not defined."}},
+	}
+	for _, test := range tests {
+		_, context := unrolledNFA(t, test.text, test.start, 2)
+		if !reflect.DeepEqual(context.GetTruncatedRules(), test.truncated) {
+			t.Errorf("%s: truncated %v, want %v", test.name, context.GetTruncatedRules(), test.truncated)
+		}
+		unhandled := make([]string, 0)
+		for _, key := range context.GetUnhandledRules() {
+			unhandled = append(unhandled, context.GetUnhandledReason(key))
+		}
+		if !reflect.DeepEqual(unhandled, test.unhandled) {
+			t.Errorf("%s: unhandled %q, want %q", test.name, unhandled, test.unhandled)
+		}
+	}
+}
+
+func TestUnrollWithoutMaxDepth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("recursive rule is expanded without a maximum depth")
+		}
+	}()
+	unrolledNFA(t, "p = \"(\" p \")\" / \"x\"\n", "p", NFA_NO_RECURSION)
+}
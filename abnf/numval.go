@@ -3,8 +3,8 @@ package abnf
 import (
 	"GoABNF/automata"
 	"bytes"
-	"container/list"
 	"strconv"
+	"strings"
 )
 
 /*
@@ -22,37 +22,63 @@ import (
                      [ 1*("." 1*HEXDIG) / ("-" 1*HEXDIG) ]
 */
 type NumVal struct { //implements Element {//, Terminal {
+	Span
 	base   string
 	ranged bool
-	values *list.List //private List<String> values = new ArrayList<String>();
+	values []string
 }
 
 func NewNumVal(base string, ranged bool) *NumVal {
 	this := &NumVal{}
 	this.base = base
 	this.ranged = ranged
-	this.values = list.New()
+	this.values = make([]string, 0)
 	return this
 }
 
+func (this *NumVal) GetBase() string {
+	return this.base
+}
+
+func (this *NumVal) IsRanged() bool {
+	return this.ranged
+}
+
 func (this *NumVal) AddValue(value string) {
-	this.values.PushBack(value)
+	this.values = append(this.values, value)
 }
 
 func (this *NumVal) String() string {
 	var s bytes.Buffer
 	s.WriteString("%")
 	s.WriteString(this.base)
-	for e := this.values.Front(); e != nil; e = e.Next() {
-		v := e.Value.(string)
+	for i, v := range this.values {
+		if i > 0 {
+			if this.ranged {
+				s.WriteString("-")
+			} else {
+				s.WriteString(".")
+			}
+		}
 		s.WriteString(v)
-		if e.Next() != nil {
+	}
+	return s.String()
+}
+
+//规范形式：进制符号小写，十六进制数字大写，例如%x41-5A、%d13.10
+func (this *NumVal) CanonicalString() string {
+	var s bytes.Buffer
+	s.WriteString("%")
+	s.WriteString(strings.ToLower(this.base))
+	for i, v := range this.values {
+		if i > 0 {
 			if this.ranged {
 				s.WriteString("-")
 			} else {
 				s.WriteString(".")
 			}
 		}
+		s.WriteString(strings.ToUpper(v))
 	}
 	return s.String()
 }
@@ -65,16 +91,16 @@ func (this *NumVal) GetDependentRuleNames() Set_RuleName {
 	return make(Set_RuleName)
 }
 
-func (this *NumVal) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *NumVal) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
 //@Override
-func (this *NumVal) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
-	if this.values.Len() == 0 {
+func (this *NumVal) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
+	if len(this.values) == 0 {
 		startState.AddTransitEpsilon(acceptingState)
 		return
 	}
@@ -90,16 +116,20 @@ func (this *NumVal) GetNFAStates(startState, acceptingState *automata.NFAState,
 		panic("NumVal base can not be handled.")
 	}
 
+	//范围型数值（如%x41-5A）只有两个值，表示闭区间内的任一字节，生成一条区间迁移
+	if this.ranged {
+		from, _ := strconv.ParseInt(this.values[0], radix, 64)
+		to, _ := strconv.ParseInt(this.values[len(this.values)-1], radix, 64)
+		startState.AddTransitRange2(int(from), int(to), acceptingState)
+		return
+	}
+
 	current := startState
-	e := this.values.Front()
-	for j := 0; j < this.values.Len()-1; j++ {
-		v := e.Value.(string)
-		i, _ := strconv.ParseInt(v, radix, 64)
+	for j := 0; j < len(this.values)-1; j++ {
+		i, _ := strconv.ParseInt(this.values[j], radix, 64)
 		current = current.AddTransitInt1(int(i))
-		e = e.Next()
 	}
-	v := e.Value.(string)
-	i, _ := strconv.ParseInt(v, radix, 64)
+	i, _ := strconv.ParseInt(this.values[len(this.values)-1], radix, 64)
 	current.AddTransitInt2(int(i), acceptingState)
 }
 
@@ -138,6 +168,6 @@ func (this *HexVal) Expected() string {
 	return "['0'-'9', 'A'-'F', 'a'-'f']"
 }
 
-func (this *NumVal) GetValues() *list.List {
+func (this *NumVal) GetValues() []string {
 	return this.values
 }
@@ -0,0 +1,36 @@
+package abnf
+
+import (
+	"testing"
+)
+
+func TestNumValRange(t *testing.T) {
+	tests := []struct {
+		text     string
+		accepted []byte
+		rejected []byte
+	}{
+		{"a = %x41-5A\n", []byte{0x41, 0x42, 0x59, 0x5A}, []byte{0x40, 0x5B, 0x61, 0x7A}},
+		{"a = %d65-90\n", []byte{0x41, 0x5A}, []byte{0x40, 0x5B}},
+		{"a = %b1000001-1011010\n", []byte{0x41, 0x5A}, []byte{0x40, 0x5B}},
+		{"a = %x00-FF\n", []byte{0x00, 0x80, 0xFF}, nil},
+		//    单个数值不做大小写折叠
+		{"a = %x41\n", []byte{0x41}, []byte{0x40, 0x42, 0x61}},
+	}
+	for _, test := range tests {
+		nfa, _ := unrolledNFA(t, test.text, "a", NFA_NO_RECURSION)
+		for _, b := range test.accepted {
+			if !nfa.Match([]byte{b}) {
+				t.Errorf("%q: %%x%02X is not accepted", test.text, b)
+			}
+		}
+		for _, b := range test.rejected {
+			if nfa.Match([]byte{b}) {
+				t.Errorf("%q: %%x%02X is accepted", test.text, b)
+			}
+		}
+		if nfa.Match([]byte{0x41, 0x41}) {
+			t.Errorf("%q: two bytes are accepted", test.text)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 //  option         =  "[" *c-wsp alternation *c-wsp "]"
 
 type Option struct { //implements Element {
+	Span
+	Trivia
 	alternation *Alternation
 }
 
@@ -32,14 +34,14 @@ func (this *Option) GetDependentRuleNames() Set_RuleName {
 	return this.alternation.GetDependentRuleNames()
 }
 
-func (this *Option) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Option) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Option) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
+func (this *Option) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
 	startState.AddTransitEpsilon(acceptingState)
-	this.alternation.GetNFAStates(startState, acceptingState, rules)
+	this.alternation.GetNFAStates(startState, acceptingState, context)
 }
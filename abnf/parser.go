@@ -2,15 +2,39 @@ package abnf
 
 import (
 	"bytes"
-	"container/list"
-	"fmt"
 	"io"
 	"strconv"
 )
 
+//    行结束符的处理方式
+type LineEnding int
+
+const (
+	//    宽松模式（默认）：CRLF、单独的LF、单独的CR都作为行结束符
+	LINE_ENDING_LENIENT LineEnding = iota
+	//    严格模式：按照RFC 5234，只接受CRLF
+	LINE_ENDING_CRLF
+)
+
 //    ABNF文法解析器
 type Parser struct {
-	//prefix string
+	//    规则名的前缀，加在这个文件中定义和引用的每个规则名之前，例如"RFC3261-"
+	prefix string
+
+	//    文法文件名，用于错误信息
+	fileName string
+
+	//    行结束符的处理方式
+	lineEnding LineEnding
+
+	//    是否自动加入被引用但没有定义的RFC 5234核心规则（见Grammar.ResolveCoreRules）
+	coreRules bool
+
+	//    已经读入但还没有归属到语法树节点上的注释
+	comments []string
+
+	//    最后一条规则之后的注释行和空行
+	trailingLines []string
 
 	//    ABNF文法解析器的输入流，这是一个支持peek和read操作的输入流，
 	//    支持peek是因为这是一个预测解析器，即需要向前看1～2个字符，
@@ -21,14 +45,151 @@ type Parser struct {
 //    构造函数，设置规则名的前缀和输入源，并将普通的输入源转化为支持peek操作的输入源。
 func NewParser(reader io.Reader) *Parser {
 	this := &Parser{}
-	//this.prefix = prefix
 	this.peeker = NewPeeker(reader)
+	this.SetLineEnding(LINE_ENDING_LENIENT)
 	return this
 }
 
-//        调用parse函数开始对输入源进行解析，返回输入源中定义的ABNF规则列表
-func (this *Parser) Parse() (*list.List, error) {
-	return this.rulelist()
+//    设置规则名的前缀，需要在Parse之前调用
+func (this *Parser) SetPrefix(prefix string) {
+	this.prefix = prefix
+}
+
+func (this *Parser) GetPrefix() string {
+	return this.prefix
+}
+
+//    设置文法文件名，Parse返回的MatchException和CollisionException会带上这个文件名
+func (this *Parser) SetFileName(fileName string) {
+	this.fileName = fileName
+}
+
+func (this *Parser) GetFileName() string {
+	return this.fileName
+}
+
+//    设置行结束符的处理方式，需要在Parse之前调用
+func (this *Parser) SetLineEnding(lineEnding LineEnding) {
+	this.lineEnding = lineEnding
+}
+
+func (this *Parser) GetLineEnding() LineEnding {
+	return this.lineEnding
+}
+
+//    设置是否自动解析RFC 5234核心规则（ALPHA、DIGIT、CRLF等），
+//    打开时，文法中引用了但没有定义的核心规则会被加入到Parse返回的Grammar的末尾
+func (this *Parser) SetCoreRules(coreRules bool) {
+	this.coreRules = coreRules
+}
+
+func (this *Parser) IsCoreRules() bool {
+	return this.coreRules
+}
+
+//    返回下一个将要读入的字节在文法文件中的位置
+func (this *Parser) position() Position {
+	return Position{this.peeker.GetLine(), this.peeker.GetPos(), this.peeker.GetOffset()}
+}
+
+//    取出已经读入但还没有归属的注释
+func (this *Parser) takeComments() []string {
+	comments := this.comments
+	this.comments = nil
+	return comments
+}
+
+//    返回最后一条规则之后的注释行和空行，注释行以分号开头，空行为空字符串
+func (this *Parser) GetTrailingLines() []string {
+	return this.trailingLines
+}
+
+//    判断接下来是否是一个c-wsp，即WSP，或者c-nl之后紧跟着WSP（续行）。
+//    c-nl后面如果不是WSP，则它是规则的结尾，而不是规则中间的空白，
+//    这需要越过整条注释向前看，Peeker支持任意深度的peek
+func (this *Parser) MatchCWsp() bool {
+	value := this.peeker.Peek(0)
+	if value == 0x20 || value == 0x09 {
+		return true
+	}
+	depth := 0
+	if value == ';' {
+		depth++
+		for this.MatchExpected(this.peeker.Peek(depth), 0x20) || this.MatchExpected(this.peeker.Peek(depth), 0x09) || this.MatchRange(this.peeker.Peek(depth), 0x21, 0x7E) {
+			depth++
+		}
+	}
+	switch this.peeker.Peek(depth) {
+	case 0x0D:
+		depth++
+		if this.peeker.Peek(depth) == 0x0A {
+			depth++
+		} else if this.lineEnding != LINE_ENDING_LENIENT {
+			return false
+		}
+	case 0x0A:
+		if this.lineEnding != LINE_ENDING_LENIENT {
+			return false
+		}
+		depth++
+	default:
+		return false
+	}
+	value = this.peeker.Peek(depth)
+	return value == 0x20 || value == 0x09
+}
+
+//    判断字符是否可能是repetition的开始，即FIRST(repetition)
+func (this *Parser) MatchRepetition(value int) bool {
+	return this.MatchRange(value, 0x30, 0x39) || this.MatchExpected(value, '*') ||
+		this.MatchRange(value, 0x41, 0x5A) || this.MatchRange(value, 0x61, 0x7A) ||
+		this.MatchExpectedChars(value, []int{'(', '[', 0x22, '%', '<'})
+}
+
+//    判断字符是否是行结束符的开始：严格模式下只有CR，宽松模式下CR和LF都是
+func (this *Parser) MatchNewline(value int) bool {
+	return value == 0x0D || (this.lineEnding == LINE_ENDING_LENIENT && value == 0x0A)
+}
+
+//    解析一个行结束符：严格模式下必须是CRLF，
+//    宽松模式下可以是CRLF、单独的LF或者单独的CR
+func (this *Parser) newline() string {
+	if this.lineEnding != LINE_ENDING_LENIENT {
+		return this.CRLF()
+	}
+	switch this.peeker.Peek(0) {
+	case 0x0D:
+		if this.peeker.Peek(1) == 0x0A {
+			return this.CRLF()
+		}
+		return this.CR()
+	case 0x0A:
+		return this.LF()
+	default:
+		panic(NewMatchException("[%x0D, %x0A]", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
+	}
+}
+
+//        调用parse函数开始对输入源进行解析，返回输入源中定义的ABNF规则（Grammar）
+//        解析出错时仍然返回成功解析的规则，error是包含所有错误的ErrorList，
+//        其中的每个错误是*MatchException或*CollisionException，
+//        可以通过errors.As取得出错的行列位置等信息
+func (this *Parser) Parse() (*Grammar, error) {
+	grammar, err := this.rulelist()
+	if errs, ok := err.(ErrorList); ok && this.fileName != "" {
+		for _, e := range errs {
+			switch exception := e.(type) {
+			case *MatchException:
+				exception.File = this.fileName
+			case *CollisionException:
+				exception.File = this.fileName
+			}
+		}
+	}
+	if this.coreRules {
+		grammar.ResolveCoreRules()
+	}
+	return grammar, err
 }
 
 //    match函数用来判断两个字符是否相同
@@ -69,7 +230,7 @@ func (this *Parser) MatchExpectedChars(value int, expected []int) bool {
 //    MatchException中包含了产生匹配异常的符号输入流中的行列位置，以及期待的字符。
 func (this *Parser) AssertMatchExpected(value, expected int) {
 	if !this.MatchExpected(value, expected) {
-		panic(NewMatchException("'"+strconv.Itoa(int(expected))+"' ["+fmt.Sprintf("%02X", expected)+"]", int(value), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException(charOf(expected), int(value), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -77,10 +238,7 @@ func (this *Parser) AssertMatchExpected(value, expected int) {
 //    MatchException中包含了产生匹配异常的符号输入流中的行列位置，以及期待的字符。
 func (this *Parser) AssertMatchRange(value, lower, upper int) {
 	if !this.MatchRange(value, lower, upper) {
-		panic(NewMatchException(
-			"'"+strconv.Itoa(int(lower))+"'~'"+strconv.Itoa(int(upper))+"' "+
-				"["+fmt.Sprintf("%02X", lower)+"~"+fmt.Sprintf("%02X", lower)+"]",
-			int(value), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException(rangeOf(lower, upper), int(value), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -88,7 +246,7 @@ func (this *Parser) AssertMatchRange(value, lower, upper int) {
 //    MatchException中包含了产生匹配异常的符号输入流中的行列位置，以及期待的字符。
 func (this *Parser) AssertMatchExpectedIgnoreCase(value int, expected byte) {
 	if !this.MatchExpectedIgnoreCase(value, expected) {
-		panic(NewMatchException("'"+string(expected)+"' ["+fmt.Sprintf("%02X", expected)+"]", int(value), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException(charOf(int(expected)), int(value), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -106,7 +264,7 @@ func (this *Parser) ALPHA() string {
 	}
 
 	value := this.peeker.Read()
-	return string(value)
+	return string(rune(value))
 }
 
 //BIT			= "0" / "1"
@@ -114,7 +272,7 @@ func (this *Parser) BIT() string {
 	this.AssertMatchRange(this.peeker.Peek(0), 0x30, 0x31)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  CHAR          =  %x01-7E
@@ -122,7 +280,7 @@ func (this *Parser) CHAR() string {
 	this.AssertMatchRange(this.peeker.Peek(0), 0x01, 0x7E)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  CR             =  %x0D
@@ -131,7 +289,7 @@ func (this *Parser) CR() string {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x0D)
 	value := this.peeker.Read()
 	//      返回回车的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  CRLF           =  CR LF
@@ -150,7 +308,7 @@ func (this *Parser) CTL() string {
 	}
 
 	value := this.peeker.Read()
-	return string(value)
+	return string(rune(value))
 }
 
 //  DIGIT          =  %x30-39
@@ -158,7 +316,7 @@ func (this *Parser) DIGIT() string {
 	this.AssertMatchRange(this.peeker.Peek(0), 0x30, 0x39)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  DQUOTE          =  %x22
@@ -166,7 +324,7 @@ func (this *Parser) DQUOTE() string {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x22)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //HEXDIG            =  DIGIT/"A"/"B"/"C"/"D"/"E"/"F"
@@ -179,7 +337,7 @@ func (this *Parser) HEXDIG() string {
 	}
 
 	value := this.peeker.Read()
-	return string(value)
+	return string(rune(value))
 }
 
 //  HTAB           =  %x09
@@ -188,7 +346,7 @@ func (this *Parser) HTAB() string {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x09)
 	value := this.peeker.Read()
 	//      返回HTAB的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  LF             =  %x0A
@@ -197,7 +355,7 @@ func (this *Parser) LF() string {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x0A)
 	value := this.peeker.Read()
 	//      返回换行的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 // LWSP			= *(WSP / CRLF WSP) ?
@@ -205,7 +363,7 @@ func (this *Parser) LWSP() string {
 	//this.AssertMatchRange(this.peeker.Peek(0), 0x00, 0xFF)
 	//value := this.peeker.Read()
 	//      返回空格的字符串值
-	//return string(value)
+	//return string(rune(value))
 	println("LWSP TODO")
 	return ""
 }
@@ -215,7 +373,7 @@ func (this *Parser) OCTET() string {
 	this.AssertMatchRange(this.peeker.Peek(0), 0x00, 0xFF)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  SP             =  %x20
@@ -224,7 +382,7 @@ func (this *Parser) SP() string {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x20)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //  VCHAR          =  %x21-7E
@@ -232,7 +390,7 @@ func (this *Parser) VCHAR() string {
 	this.AssertMatchRange(this.peeker.Peek(0), 0x21, 0x7E)
 	value := this.peeker.Read()
 	//      返回空格的字符串值
-	return string(value)
+	return string(rune(value))
 }
 
 //WSP            =  SP / HTAB
@@ -247,8 +405,8 @@ func (this *Parser) WSP() string {
 		return this.HTAB()
 		//              否则抛出匹配异常MatchException
 	default:
-		panic(NewMatchException("[0x20, 0x09]", int(this.peeker.Peek(0)),
-			this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException("[%x20, %x09]", int(this.peeker.Peek(0)),
+			this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -264,6 +422,7 @@ func (this *Parser) rule() *Rule {
 	//println(rulename.String())
 	//              rulename后面紧接着defined-as元素，调用相应的方法
 	definedAs := this.defined_as()
+	comments := this.takeComments()
 	//println(definedAs)
 	//              defined-as后面接着elements元素，调用elements()
 	elements := this.elements()
@@ -271,8 +430,14 @@ func (this *Parser) rule() *Rule {
 	//              elements后面接着c-nl元素，调用之。
 	this.c_nl()
 
-	//              返回解析到的规则
-	return NewRule(rulename, definedAs, elements)
+	//              返回解析到的规则，规则的范围从规则名开始到elements结束（不含结尾的空格和换行）
+	rule := NewRule(rulename, definedAs, elements)
+	rule.SetSpan(rulename.GetStart(), elements.GetEnd())
+	rule.SetFileName(this.fileName)
+	//              defined-as前后的注释和行尾的注释归属于规则本身
+	rule.AddComments(comments...)
+	rule.AddComments(this.takeComments()...)
+	return rule
 }
 
 //              c-nl           =  comment / CRLF
@@ -282,14 +447,15 @@ func (this *Parser) c_nl() string {
 	//              如果是分号，则是注释，调用comment()方法进行解析
 	case ';':
 		return this.comment()
-		//              如果是0x0D，则是回车，调用CRLF()方法进行解析
-	case 0x0D:
-		return this.CRLF()
-		//              否则抛出异常
 	case PEEKER_EOF:
 		return ""
 	default:
-		panic(NewMatchException("[';', 0x0D]", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		//              如果是行结束符，调用newline()方法进行解析
+		if this.MatchNewline(this.peeker.Peek(0)) {
+			return this.newline()
+		}
+		//              否则抛出异常
+		panic(NewMatchException("[';', %x0D, %x0A]", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -302,31 +468,41 @@ func (this *Parser) element() Element {
 	}
 
 	//      否则再检查这个字符
+	start := this.position()
+	var element Element
 	switch this.peeker.Peek(0) {
 	//          如果是左括号，则是group，调用group()
 	case '(':
-		return this.group()
+		element = this.group()
 		//          如果是左方括号，则调用option()
 	case '[':
-		return this.option()
+		element = this.option()
 		//          如果是双引号，则调用char_var()
 	case 0x22:
-		return this.char_val()
+		element = this.char_val()
 		//          如果是百分号，则调用num_val()
 	case '%':
-		return this.num_val()
+		element = this.num_val()
 		//          如果是左尖括号（小于号），则调用prose_val()
 	case '<':
-		return this.prose_val()
+		element = this.prose_val()
 		//          否则抛出匹配异常
 	default:
-		panic(NewMatchException("['(', '[', 0x22, '%', '<']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException("['(', '[', %x22, '%', '<']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
+	//      记录元素在文法文件中的范围
+	element.SetSpan(start, this.position())
+	return element
 }
 
 //              char-val       =  DQUOTE *(%x20-21 / %x23-7E) DQUOTE
 //  DQUOTE         =  %x22
 func (this *Parser) char_val() *CharVal {
+	return NewCharVal(this.quoted_string())
+}
+
+//              quoted-string  =  DQUOTE *(%x20-21 / %x23-7E) DQUOTE
+func (this *Parser) quoted_string() string {
 	var char_val bytes.Buffer
 	//      char-val是双引号开始的
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x22)
@@ -340,7 +516,15 @@ func (this *Parser) char_val() *CharVal {
 	this.AssertMatchExpected(this.peeker.Peek(0), 0x22)
 	this.peeker.Read()
 	//      返回这个字符串
-	return NewCharVal(char_val.String())
+	return char_val.String()
+}
+
+//              case-sensitive-string   =  "%s" quoted-string
+//              case-insensitive-string =  [ "%i" ] quoted-string
+//      RFC 7405扩展，百分号已经在num_val()中读入，这里读入s或i以及后面的字符串
+func (this *Parser) case_char_val(sensitivity CaseSensitivity) *CharVal {
+	this.peeker.Read()
+	return NewCharVal2(this.quoted_string(), sensitivity)
 }
 
 //              prose-val      =  "<" *(%x20-3D / %x3F-7E) ">"
@@ -363,7 +547,7 @@ func (this *Parser) c_wsp() string {
 	//              需要看这两个产生式的第一个字母，在龙书中也就时求FIRST(WSP)和
 	//              FIRST(c-nl WSP)两个函数，其中：
 	//              FIRST(WSP) = {0x20, 0x09};
-	//              FIRST(c-nl WSP) = {';', 0x0D};
+	//              FIRST(c-nl WSP) = {';', 0x0D}（宽松模式下还有0x0A）;
 	//              我们开心的看到，FIRST(WSP)和FIRST(c-nl WSP)没有交集，
 	//              因此只需要向前看一个字符就足够了。
 	switch this.peeker.Peek(0) {
@@ -371,12 +555,13 @@ func (this *Parser) c_wsp() string {
 		fallthrough
 	case 0x09: //println("i'm only wsp");
 		return this.WSP()
-	case ';':
-		fallthrough
-	case 0x0D: //println("i'm only c_nl+wsp");
+	case ';': //println("i'm only c_nl+wsp");
 		return this.c_nl() + this.WSP()
 	default:
-		panic(NewMatchException("[0x20, ';']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		if this.MatchNewline(this.peeker.Peek(0)) {
+			return this.c_nl() + this.WSP()
+		}
+		panic(NewMatchException("[%x20, ';']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -401,8 +586,12 @@ func (this *Parser) comment() string {
 		//          if (peekMatch ==0x20 || peekMatch == 0x09) WSP();
 		//          else if (peekMatch >= 0x21 && peekMatch <= 0x7E) VCHAR();
 	}
-	//      结束之前要匹配回车换行字符
-	comment.WriteString(this.CRLF())
+	//      记录注释的内容（不含行结束符），由调用者归属到语法树节点上
+	this.comments = append(this.comments, comment.String())
+	//      结束之前要匹配回车换行字符，与c-nl一样，最后一行的注释可以直接以输入结束
+	if this.peeker.Peek(0) != PEEKER_EOF {
+		comment.WriteString(this.newline())
+	}
 	return comment.String()
 }
 
@@ -423,7 +612,7 @@ func (this *Parser) val(base byte, matcher Matcher) Element {
 		//println(from.String());
 		//          第一个数值后面如果是跟着点号，则是一个数列NumVal，如果是－破折号，则是一个范围型数值RangedNumVal，如果都不是，则是单一个数值
 		if this.MatchExpected(this.peeker.Peek(0), '.') {
-			numval := NewNumVal(string(baseValue), false)
+			numval := NewNumVal(string(rune(baseValue)), false)
 			//              将刚才匹配到的数值作为第一个数值加到将要返回的NumVal中
 			numval.AddValue(from.String())
 			//              如果后面跟着点号，则继续加入新的数值到NumVal中
@@ -446,13 +635,13 @@ func (this *Parser) val(base byte, matcher Matcher) Element {
 		} else if this.MatchExpected(this.peeker.Peek(0), '-') {
 			//              这里向前读取两个字符，因此即使破折号后面跟着的不是数字，也能返回单一个数字而且将破折号留给后面的分析程序
 			//              这是本程序里为数不多的能够具备回溯的代码段之一，嘿嘿。
-			numval := NewNumVal(string(baseValue), true)
+			numval := NewNumVal(string(rune(baseValue)), true)
 			numval.AddValue(from.String())
 
 			next := this.peeker.Peek(1)
 			if !(matcher.Match(next)) {
 				//                  如果破折号后面跟的不是数字，则破折号不读入，返回单一数值
-				numval := NewNumVal(string(baseValue), false)
+				numval := NewNumVal(string(rune(baseValue)), false)
 				numval.AddValue(from.String())
 				return numval
 			} else {
@@ -469,18 +658,18 @@ func (this *Parser) val(base byte, matcher Matcher) Element {
 		} else {
 			//println("i am other");
 			//              第一个数值之后跟的既不是点号，也不是破折号，则返回单一数值
-			numval := NewNumVal(string(baseValue), false)
+			numval := NewNumVal(string(rune(baseValue)), false)
 			numval.AddValue(from.String())
 			return numval
 		}
 	} else {
-		panic(NewMatchException(matcher.Expected(), int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException(matcher.Expected(), int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 
 }
 
 //              num-val        =  "%" (bin-val / dec-val / hex-val)
-//      解析num-val
+//      解析num-val，以及RFC 7405中以百分号开头的char-val
 func (this *Parser) num_val() Element {
 	//String base = "", from ="", val ="";
 	//              百分号开头
@@ -503,8 +692,17 @@ func (this *Parser) num_val() Element {
 	case 'X':
 		var hex HexVal
 		return this.val('x', &hex)
+	//              RFC 7405：%s"..."大小写敏感，%i"..."大小写不敏感
+	case 's':
+		fallthrough
+	case 'S':
+		return this.case_char_val(CASE_SENSITIVE)
+	case 'i':
+		fallthrough
+	case 'I':
+		return this.case_char_val(CASE_INSENSITIVE)
 	default:
-		panic(NewMatchException("['b', 'd', 'x']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException("['b', 'd', 'x', 's', 'i']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -512,13 +710,16 @@ func (this *Parser) num_val() Element {
 //    DIGIT          =  %x30-39
 func (this *Parser) repetition() *Repetition {
 	var r *Repeat
+	start := this.position()
 	//      若以数字或者星号开头，则进入repeat
 	if this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) || this.MatchExpected(this.peeker.Peek(0), '*') {
 		r = this.repeat()
 	}
 	//      element是必须的
 	e := this.element()
-	return NewRepetition(r, e)
+	repetition := NewRepetition(r, e)
+	repetition.SetSpan(start, e.GetEnd())
+	return repetition
 }
 
 //              repeat         =  1*DIGIT / (*DIGIT "*" *DIGIT)
@@ -532,7 +733,7 @@ func (this *Parser) repeat() *Repeat {
 		if this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
 			max = 0;
 			for this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
-				i, _ := strconv.Atoi(string(this.peeker.Read()))
+				i, _ := strconv.Atoi(string(rune(this.peeker.Read())))
 				max = max*10 + i
 			}
 		}
@@ -540,7 +741,7 @@ func (this *Parser) repeat() *Repeat {
 	} else if this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
 		//      repeat是以数字开头，其值表示重复的最小次数
 		for this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
-			i, _ := strconv.Atoi(string(this.peeker.Read()))
+			i, _ := strconv.Atoi(string(rune(this.peeker.Read())))
 			min = min*10 + i
 		}
 		//          如果有星号，则表示有范围
@@ -550,7 +751,7 @@ func (this *Parser) repeat() *Repeat {
 			if this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
 				max = 0;
 				for this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) {
-					i, _ := strconv.Atoi(string(this.peeker.Read()))
+					i, _ := strconv.Atoi(string(rune(this.peeker.Read())))
 					max = max*10 + i
 				}
 			}
@@ -560,7 +761,7 @@ func (this *Parser) repeat() *Repeat {
 			return NewRepeat(min, max, false)
 		}
 	} else {
-		panic(NewMatchException("['0'-'9', '*']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException("['0'-'9', '*']", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
 }
 
@@ -569,26 +770,32 @@ func (this *Parser) repeat() *Repeat {
 func (this *Parser) alternation() *Alternation {
 	alternation := NewAlternation()
 	//              每个alternation至少有一个候选项，这个候选项的类型是concatenation（连结项）
-	alternation.AddConcatenation(this.concatenation())
+	first := this.concatenation()
+	alternation.AddConcatenation(first)
+	alternation.SetSpan(first.GetStart(), first.GetEnd())
+	last := first
 	//      从第二个候选项开始，每个候选项都是都是以空格（可选）以及“/”引导的，
-	//      因此，只要遇到空格或者/号，就认为接下来的又是一个候选项
-	//      当然，如果遇到空格但后面跟的不是/号，又或者如果/号之后跟的不是候选项，
-	//      那就只能异常了，因为这个算法不能回溯到空格或者/号之前
-	var chars = []int{0x20, ';', '/'}
-	for this.MatchExpectedChars(this.peeker.Peek(0), chars) {
+	//      concatenation()会吃掉它后面的空格，所以这里只需要检查空格（可能是concatenation
+	//      后面没有吃掉的c-wsp）或者/号，遇到/号就认为接下来的又是一个候选项
+	for this.MatchCWsp() || this.MatchExpected(this.peeker.Peek(0), '/') {
 		//          如遇到空格或者分号，则进入c_wsp()
-		for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') {
+		for this.MatchCWsp() {
 			this.c_wsp()
 		}
 		//          此处必须是/号了，否则异常，没有办法回溯
 		this.AssertMatchExpected(this.peeker.Peek(0), '/')
 		this.peeker.Read()
 		//          /号后面可以跟若干空格或注释
-		for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') {
+		for this.MatchCWsp() {
 			this.c_wsp()
 		}
+		//          /号前后的注释归属于前一个候选项的最后一个repetition
+		last.GetLastRepetition().AddComments(this.takeComments()...)
 		//          空格之后的新的候选项，候选项本身是concatenation，所以进入相应的函数。
-		alternation.AddConcatenation(this.concatenation())
+		next := this.concatenation()
+		alternation.AddConcatenation(next)
+		alternation.SetSpan(alternation.GetStart(), next.GetEnd())
+		last = next
 	}
 	return alternation
 }
@@ -598,16 +805,24 @@ func (this *Parser) concatenation() *Concatenation {
 	concatenation := NewConcatenation()
 	//              一个concatenation是由至少一个repetition组成的，
 	//              这些repetition有先后顺序之分，用若干空格隔开
-	concatenation.AddRepetition(this.repetition())
-	//      后面有空格或分号，则认为会接着一个repetition
-	//      其实这样是不严谨的，因为空格后面其实不必然是repetition，
-	//      也可能是其他文法单位，但作为一个手工编写的解析器
-	//      暂时接受它诸多的缺陷吧。
-	for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') {
-		for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') {
+	current := this.repetition()
+	concatenation.AddRepetition(current)
+	concatenation.SetSpan(current.GetStart(), current.GetEnd())
+	//      后面有c-wsp，则可能接着一个repetition；
+	//      空格后面也可能是/号、右括号或者规则的结束，这时候空格已经被吃掉了，
+	//      但c-wsp本来就可以忽略，所以由调用者继续处理后面的符号即可。
+	for this.MatchCWsp() {
+		for this.MatchCWsp() {
 			this.c_wsp()
 		}
-		concatenation.AddRepetition(this.repetition())
+		//          空格中的注释归属于它前面的repetition
+		current.AddComments(this.takeComments()...)
+		if !this.MatchRepetition(this.peeker.Peek(0)) {
+			break
+		}
+		current = this.repetition()
+		concatenation.AddRepetition(current)
+		concatenation.SetSpan(concatenation.GetStart(), current.GetEnd())
 	}
 	return concatenation
 }
@@ -618,19 +833,22 @@ func (this *Parser) group() *Group {
 	this.AssertMatchExpected(this.peeker.Peek(0), '(')
 	this.peeker.Read()
 	//      括号后面的若干空格
-	var chars = []int{0x20, ';', 0x0D}
-	for this.MatchExpectedChars(this.peeker.Peek(0), chars) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
+	comments := this.takeComments()
 	//      一个group包含一个alternation
 	alternation := this.alternation()
-	for this.MatchExpectedChars(this.peeker.Peek(0), chars) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
 	//      以右圆括号结束
 	this.AssertMatchExpected(this.peeker.Peek(0), ')')
 	this.peeker.Read()
-	return NewGroup(alternation)
+	group := NewGroup(alternation)
+	group.AddComments(comments...)
+	group.AddComments(this.takeComments()...)
+	return group
 }
 
 //              option         =  "[" *c-wsp alternation *c-wsp "]"
@@ -638,69 +856,180 @@ func (this *Parser) group() *Group {
 func (this *Parser) option() *Option {
 	this.AssertMatchExpected(this.peeker.Peek(0), '[')
 	this.peeker.Read()
-	var chars = []int{0x20, ';', 0x0D}
-	for this.MatchExpectedChars(this.peeker.Peek(0), chars) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
+	comments := this.takeComments()
 	alternation := this.alternation()
-	for this.MatchExpectedChars(this.peeker.Peek(0), chars) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
 	this.AssertMatchExpected(this.peeker.Peek(0), ']')
 	this.peeker.Read()
-	return NewOption(alternation)
+	option := NewOption(alternation)
+	option.AddComments(comments...)
+	option.AddComments(this.takeComments()...)
+	return option
 }
 
 //     rulelist       =  1*( rule / (*c-wsp c-nl) )
-func (this *Parser) rulelist() (rl *list.List, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			var ok bool
-			err, ok = r.(error)
-			if !ok {
-				err = fmt.Errorf("%v", r)
-			}
-		}
-	}()
-
-	ruleMap := make(map[*RuleName]*Rule) //Map<RuleName, Rule> ruleMap = new HashMap<RuleName, Rule>();
-	ruleList := list.New()               //List<Rule> ruleList = new ArrayList<Rule>();
-	//      如果前向字符是字母、空格、分号、回车，则认为是rule、c-wsp或者c-nl
-	for this.MatchRange(this.peeker.Peek(0), 0x41, 0x5A) || this.MatchRange(this.peeker.Peek(0), 0x61, 0x7A) || this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') || this.MatchExpected(this.peeker.Peek(0), 0x0D) {
-		//          如果是字母开头，则认为是rule，否则是c-wsp或者c-nl
+//     遇到语法错误时不会立即停止，而是记录错误并跳到下一条规则的开始处（见resync）继续解析，
+//     最后返回成功解析的规则以及所有错误（ErrorList），没有错误时error为nil
+func (this *Parser) rulelist() (*Grammar, error) {
+	errs := make(ErrorList, 0)
+	grammar := NewGrammar()
+	//      只有增量定义（=/）而尚未见到基本定义（=）的规则，记录其第一次出现的位置
+	incremental := make(map[string][2]int)
+	//      下一条规则之前的注释行和空行
+	var leadingLines []string
+	for this.peeker.Peek(0) != PEEKER_EOF {
+		//          如果是字母开头，则认为是rule
 		if this.MatchRange(this.peeker.Peek(0), 0x41, 0x5A) || this.MatchRange(this.peeker.Peek(0), 0x61, 0x7A) {
-			//              解析一条规则
-			rule := this.rule()
-			//              判断该条规则是否已经有有定义
-			if defined, present := ruleMap[rule.GetRuleName()]; !present {
+			pos, line := this.peeker.GetPos(), this.peeker.GetLine()
+			//              解析一条规则，出错则跳过这条规则
+			var rule *Rule
+			if err := this.recoverable(func() { rule = this.rule() }); err != nil {
+				errs = append(errs, err)
+				this.takeComments()
+				this.resync()
+				continue
+			}
+			rule.SetLeadingLines(leadingLines)
+			leadingLines = nil
+			//              规则名大小写不敏感，按规范形式判断该条规则是否已经有定义
+			key := rule.GetRuleName().GetKey()
+			if defined := grammar.Lookup(key); defined == nil {
 				//                  如果没有定义则放入规则列表
-				ruleMap[rule.GetRuleName()] = rule
-				ruleList.PushBack(rule)
+				grammar.AddRule(rule)
+				if rule.GetDefinedAs() == "=/" {
+					incremental[key] = [2]int{pos, line}
+				}
 			} else {
 				//                  已有定义，则检查定义方式是否为增量定义
-				//Rule defined := ruleMap.get(rule.getRuleName());
 				if rule.GetDefinedAs() == "=" && defined.GetDefinedAs() == "=" {
-					//                      如果不是增量定义，则抛出重复定义异常
-					panic(NewCollisionException(rule.GetRuleName().String()+" is redefined.", this.peeker.GetPos(), this.peeker.GetLine()).String())
+					//                      如果不是增量定义，则记录重复定义异常，保留第一次的定义
+					errs = append(errs, NewCollisionException(rule.GetRuleName().String()+" is redefined.", pos, line))
+					continue
 				}
-				//                  如果是增量定义则合并两条规则
+				//                  如果是增量定义则合并两条规则，基本定义的候选项总是排在增量定义之前
+				mergeRule(defined, rule)
 				if rule.GetDefinedAs() == "=" {
-					defined.SetDefinedAs("=")
+					delete(incremental, key)
 				}
-				defined.GetElements().GetAlternation().GetConcatenations().PushBackList(rule.GetElements().GetAlternation().GetConcatenations())
 			}
 			//println(rule.String())
+		} else if this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), 0x09) || this.MatchExpected(this.peeker.Peek(0), ';') || this.MatchNewline(this.peeker.Peek(0)) {
+			//              空格、分号、回车，则是*c-wsp c-nl，即空行或注释行
+			if err := this.recoverable(func() {
+				for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), 0x09) {
+					this.WSP()
+				}
+				this.c_nl()
+			}); err != nil {
+				errs = append(errs, err)
+				this.takeComments()
+				this.resync()
+				continue
+			}
+			//              注释行记录注释的内容，空行记录为空字符串
+			if comments := this.takeComments(); len(comments) > 0 {
+				leadingLines = append(leadingLines, comments...)
+			} else {
+				leadingLines = append(leadingLines, "")
+			}
 		} else {
-			//println("begin *c-wsp c-nl")
-			//              空格、分号、回车，则是c_wsp
-			//for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), ';') || this.MatchExpected(this.peeker.Peek(0), 0x0D) {
-			//	this.c_wsp()
-			//}
-			this.c_nl()
-			//println("")
+			//              其他字符都不可能是一行的开始
+			errs = append(errs, NewMatchException("['A'-'Z', 'a'-'z', %x20, %x09, ';', %x0D]", this.peeker.Peek(0), this.peeker.GetPos(), this.peeker.GetLine()))
+			this.resync()
+		}
+	}
+	this.trailingLines = leadingLines
+	//      增量定义必须有对应的基本定义，否则报告第一处增量定义的位置，
+	//      并且不返回这样的规则，使返回的文法中只有基本定义（=）
+	if len(incremental) > 0 {
+		rules := make([]*Rule, 0, grammar.Len())
+		for _, rule := range grammar.GetRules() {
+			if at, present := incremental[rule.GetRuleName().GetKey()]; present {
+				errs = append(errs, NewCollisionException(rule.GetRuleName().String()+" is incrementally defined (=/) without a base definition (=).", at[0], at[1]))
+				continue
+			}
+			rules = append(rules, rule)
+		}
+		grammar = NewGrammar2(rules)
+	}
+	if len(errs) > 0 {
+		return grammar, errs
+	}
+	return grammar, nil
+}
+
+//     把后出现的规则rule合并到已有的同名规则defined中，rule是基本定义时它的候选项排在前面。
+//     为了写回时不丢失注释，排在前面的候选项之后的注释（原来在行尾的注释，以及rule之前的注释行）
+//     移到它们的最后一个元素之后，排在后面的候选项的行尾注释成为合并后规则的注释
+func mergeRule(defined, rule *Rule) {
+	alternation := defined.GetElements().GetAlternation()
+	var comments []string
+	for _, line := range rule.GetLeadingLines() {
+		if line != "" {
+			comments = append(comments, line)
+		}
+	}
+	if rule.GetDefinedAs() == "=" {
+		defined.SetDefinedAs("=")
+		//      rule之前的注释行仍然写在合并后规则的前面
+		defined.SetLeadingLines(append(append([]string(nil), defined.GetLeadingLines()...), comments...))
+		concatenations := rule.GetElements().GetAlternation().GetConcatenations()
+		concatenations[len(concatenations)-1].GetLastRepetition().AddComments(rule.GetComments()...)
+		alternation.SetConcatenations(append(concatenations, alternation.GetConcatenations()...))
+	} else {
+		concatenations := alternation.GetConcatenations()
+		last := concatenations[len(concatenations)-1].GetLastRepetition()
+		last.AddComments(defined.GetComments()...)
+		last.AddComments(comments...)
+		defined.SetComments(rule.GetComments())
+		alternation.SetConcatenations(append(concatenations, rule.GetElements().GetAlternation().GetConcatenations()...))
+	}
+}
+
+//     执行一段解析过程，把其中抛出的MatchException和CollisionException转换为error返回，
+//     其他的panic（例如runtime.Error）是程序的错误而不是输入的错误，继续向上抛出
+func (this *Parser) recoverable(parse func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch exception := r.(type) {
+			case *MatchException:
+				err = exception
+			case *CollisionException:
+				err = exception
+			default:
+				panic(r)
+			}
+		}
+	}()
+	parse()
+	return nil
+}
+
+//     出错之后的同步：跳过输入直到下一条规则的开始，即以字母（规则名）开头的一行，或者输入结束
+func (this *Parser) resync() {
+	isRuleStart := func() bool {
+		return this.MatchRange(this.peeker.Peek(0), 0x41, 0x5A) || this.MatchRange(this.peeker.Peek(0), 0x61, 0x7A)
+	}
+	//      出错的位置可能恰好就是下一行的行首
+	if this.peeker.GetPos() == 1 && isRuleStart() {
+		return
+	}
+	for {
+		value := this.peeker.Read()
+		if value == PEEKER_EOF {
+			return
+		}
+		if value == 0x0A || (value == 0x0D && this.peeker.Peek(0) != 0x0A) {
+			if isRuleStart() {
+				return
+			}
 		}
 	}
-	return ruleList, nil
 }
 
 //              rulename       =  ALPHA *(ALPHA / DIGIT / "-")
@@ -709,22 +1038,25 @@ func (this *Parser) rulename() *RuleName {
 	//       DIGIT          =  %x30-39
 	//      规则名的第一个字符必须是字母
 	if !(this.MatchRange(this.peeker.Peek(0), 0x41, 0x5A) || this.MatchRange(this.peeker.Peek(0), 0x61, 0x7A)) {
-		panic(NewMatchException("'A'-'Z'/'a'-'z'", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()).String())
+		panic(NewMatchException("'A'-'Z'/'a'-'z'", int(this.peeker.Peek(0)), this.peeker.GetPos(), this.peeker.GetLine()))
 	}
+	start := this.position()
 	var rulename bytes.Buffer //= "";
 	rulename.WriteByte(byte(this.peeker.Read()))
 	//      规则名的后续字符可以是字母、数字、破折号
 	for this.MatchRange(this.peeker.Peek(0), 0x41, 0x5A) || this.MatchRange(this.peeker.Peek(0), 0x61, 0x7A) || this.MatchRange(this.peeker.Peek(0), 0x30, 0x39) || this.MatchExpected(this.peeker.Peek(0), '-') {
 		rulename.WriteByte(byte(this.peeker.Read()))
 	}
-	return NewRuleName(rulename.String())
+	ruleName := NewRuleName(this.prefix + rulename.String())
+	ruleName.SetSpan(start, this.position())
+	return ruleName
 }
 
 //              defined-as     =  *c-wsp ("=" / "=/") *c-wsp
 func (this *Parser) defined_as() string {
 	var value bytes.Buffer //= "";
 	//      等号前面的空格
-	for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), 0x09) || this.MatchExpected(this.peeker.Peek(0), ';') || this.MatchExpected(this.peeker.Peek(0), 0x0D) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
 	//      等号
@@ -735,7 +1067,7 @@ func (this *Parser) defined_as() string {
 		value.WriteByte(byte(this.peeker.Read()))
 	}
 	//      等号后面的空格
-	for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), 0x09) || this.MatchExpected(this.peeker.Peek(0), ';') || this.MatchExpected(this.peeker.Peek(0), 0x0D) {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
 	return value.String()
@@ -745,8 +1077,10 @@ func (this *Parser) defined_as() string {
 func (this *Parser) elements() *Elements {
 	//              元素elements其实就是alternation再接着若干空格
 	alternation := this.alternation()
-	for this.MatchExpected(this.peeker.Peek(0), 0x20) || this.MatchExpected(this.peeker.Peek(0), 0x09) || this.MatchExpected(this.peeker.Peek(0), ';') {
+	for this.MatchCWsp() {
 		this.c_wsp()
 	}
-	return NewElements(alternation)
+	elements := NewElements(alternation)
+	elements.SetSpan(alternation.GetStart(), alternation.GetEnd())
+	return elements
 }
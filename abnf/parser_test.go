@@ -0,0 +1,227 @@
+package abnf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//    错误的行列位置
+type errorAt struct {
+	line int
+	pos  int
+}
+
+func positionOf(t *testing.T, err error) errorAt {
+	var match *MatchException
+	if errors.As(err, &match) {
+		return errorAt{match.Line, match.Pos}
+	}
+	var collision *CollisionException
+	if errors.As(err, &collision) {
+		return errorAt{collision.Line, collision.Pos}
+	}
+	t.Fatalf("unexpected error type %T: %v", err, err)
+	return errorAt{}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		errors []errorAt
+		rules  []string
+	}{
+		{"start", "1bad\r\na = \"x\"\r\nb = a\r\n", []errorAt{{1, 1}}, []string{"a", "b"}},
+		{"middle", "a = \"x\"\r\nb = \"y\" %q\r\nc = a\r\n", []errorAt{{2, 10}}, []string{"a", "c"}},
+		{"middle group", "a = \"x\"\r\nb = (a\r\nc = a\r\n", []errorAt{{2, 7}}, []string{"a", "c"}},
+		{"end", "a = \"x\"\r\nb = a\r\nc = \"y", []errorAt{{3, 7}}, []string{"a", "b"}},
+		{"end of line", "a = \"x\"\r\nc = %x\r\n", []errorAt{{2, 7}}, []string{"a"}},
+		{"incremental without base", "b = a\r\na =/ \"x\"\r\na =/ \"y\"\r\n", []errorAt{{2, 1}}, []string{"b"}},
+		{"redefined", "a = \"x\"\r\nA = \"y\"\r\nb = a\r\n", []errorAt{{2, 1}}, []string{"a", "b"}},
+		{"everywhere", "=x\r\na = \"x\"\r\nb = ]\r\nc = a\r\nd = \"", []errorAt{{1, 1}, {3, 5}, {5, 6}}, []string{"a", "c"}},
+	}
+	for _, test := range tests {
+		grammar, err := NewParser(strings.NewReader(test.text)).Parse()
+		var errs ErrorList
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: err = %v, want an ErrorList", test.name, err)
+			continue
+		}
+		positions := make([]errorAt, 0)
+		for _, e := range errs {
+			positions = append(positions, positionOf(t, e))
+		}
+		if !reflect.DeepEqual(positions, test.errors) {
+			t.Errorf("%s: errors at %v, want %v\n%v", test.name, positions, test.errors, err)
+		}
+		rules := make([]string, 0)
+		for _, rule := range grammar.GetRules() {
+			rules = append(rules, rule.GetRuleName().String())
+		}
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Errorf("%s: rules = %v, want %v", test.name, rules, test.rules)
+		}
+	}
+}
+
+func TestRecoverableRepanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("runtime error is recovered as a parse error")
+		}
+	}()
+	p := NewParser(strings.NewReader(""))
+	var rules []*Rule
+	p.recoverable(func() { _ = rules[1] })
+}
+
+func TestCommentAtEndOfInput(t *testing.T) {
+	tests := []struct {
+		text          string
+		comments      []string
+		trailingLines []string
+	}{
+		{"a = \"x\"", nil, nil},
+		{"a = \"x\" ; c", []string{"; c"}, nil},
+		{"a = \"x\" ;", []string{";"}, nil},
+		{"a = \"x\"\r\n  ; c", []string{"; c"}, nil},
+		{"a = \"x\"\r\n; c", nil, []string{"; c"}},
+	}
+	for _, test := range tests {
+		p := NewParser(strings.NewReader(test.text))
+		grammar, err := p.Parse()
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		rule := grammar.Lookup("a")
+		if rule == nil {
+			t.Errorf("%q: rule a is dropped", test.text)
+			continue
+		}
+		if !reflect.DeepEqual(rule.GetComments(), test.comments) {
+			t.Errorf("%q: comments = %q, want %q", test.text, rule.GetComments(), test.comments)
+		}
+		if !reflect.DeepEqual(p.GetTrailingLines(), test.trailingLines) {
+			t.Errorf("%q: trailing lines = %q, want %q", test.text, p.GetTrailingLines(), test.trailingLines)
+		}
+	}
+}
+
+func TestMatchExceptionString(t *testing.T) {
+	tests := []struct {
+		exception *MatchException
+		want      string
+	}{
+		{NewMatchException(charOf(')'), 0x0D, 7, 2), "Mismatch with %x0D at 2:7. Expected value is ')' [%x29]"},
+		{NewMatchException(charOf('"'), PEEKER_EOF, 7, 3), "Mismatch with end of input at 3:7. Expected value is '\"' [%x22]"},
+		{NewMatchException(rangeOf('0', '9'), 'q', 10, 2), "Mismatch with 'q' [%x71] at 2:10. Expected value is '0'-'9' [%x30-39]"},
+		{NewMatchException(rangeOf(0x00, 0x1F), 'a', 1, 1), "Mismatch with 'a' [%x61] at 1:1. Expected value is %x00-1F"},
+	}
+	for _, test := range tests {
+		if s := test.exception.String(); s != test.want {
+			t.Errorf("got %q, want %q", s, test.want)
+		}
+	}
+}
+
+func TestLineCounting(t *testing.T) {
+	//    严格模式不接受单独的LF和CR，但是行号的计算与宽松模式相同
+	tests := []struct {
+		text    string
+		strict  []errorAt
+		lenient []errorAt
+	}{
+		{"a = \"x\"\nb = %q\r\nc = \"y\" %q\r\n", []errorAt{{1, 8}, {2, 6}, {3, 10}}, []errorAt{{2, 6}, {3, 10}}},
+		{"a = \"x\"\rb = %q\r\nc = \"y\" %q\r\n", []errorAt{{2, 1}, {2, 6}, {3, 10}}, []errorAt{{2, 6}, {3, 10}}},
+	}
+	for _, test := range tests {
+		for _, lineEnding := range []LineEnding{LINE_ENDING_CRLF, LINE_ENDING_LENIENT} {
+			want := test.lenient
+			if lineEnding == LINE_ENDING_CRLF {
+				want = test.strict
+			}
+			p := NewParser(strings.NewReader(test.text))
+			p.SetLineEnding(lineEnding)
+			_, err := p.Parse()
+			positions := make([]errorAt, 0)
+			var errs ErrorList
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					positions = append(positions, positionOf(t, e))
+				}
+			}
+			if !reflect.DeepEqual(positions, want) {
+				t.Errorf("%q (line ending %d): errors at %v, want %v", test.text, lineEnding, positions, want)
+			}
+		}
+	}
+}
+
+//    规则中所有char-val的大小写敏感性
+func sensitivities(rule *Rule) []CaseSensitivity {
+	result := make([]CaseSensitivity, 0)
+	Inspect(rule.GetElements(), func(node Node) bool {
+		if charVal, ok := node.(*CharVal); ok {
+			result = append(result, charVal.GetCaseSensitivity())
+		}
+		return true
+	})
+	return result
+}
+
+func TestCaseSensitiveCharVal(t *testing.T) {
+	tests := []struct {
+		text          string
+		want          string
+		sensitivities []CaseSensitivity
+	}{
+		{"a = \"aB\"", "a = \"aB\"", []CaseSensitivity{CASE_DEFAULT}},
+		{"a = %s\"aB\"", "a = %s\"aB\"", []CaseSensitivity{CASE_SENSITIVE}},
+		{"a = %i\"aB\"", "a = %i\"aB\"", []CaseSensitivity{CASE_INSENSITIVE}},
+		{"a = %S\"x\" / %I\"y\"", "a = %s\"x\"/%i\"y\"", []CaseSensitivity{CASE_SENSITIVE, CASE_INSENSITIVE}},
+		{"a = %s\"\" \"x\" %i\"\"", "a = %s\"\" \"x\" %i\"\"", []CaseSensitivity{CASE_SENSITIVE, CASE_DEFAULT, CASE_INSENSITIVE}},
+	}
+	for _, test := range tests {
+		grammar, err := NewParser(strings.NewReader(test.text + "\r\n")).Parse()
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		rule := grammar.Lookup("a")
+		if rule.String() != test.want {
+			t.Errorf("%q: got %s, want %s", test.text, rule.String(), test.want)
+		}
+		if !reflect.DeepEqual(sensitivities(rule), test.sensitivities) {
+			t.Errorf("%q: sensitivities = %v, want %v", test.text, sensitivities(rule), test.sensitivities)
+		}
+		//    String()的结果重新解析后大小写敏感性不变
+		again, err := NewParser(strings.NewReader(rule.String() + "\r\n")).Parse()
+		if err != nil {
+			t.Errorf("%q: reparse %s: %v", test.text, rule.String(), err)
+			continue
+		}
+		if !reflect.DeepEqual(sensitivities(again.Lookup("a")), test.sensitivities) {
+			t.Errorf("%q: sensitivities after round trip = %v", test.text, sensitivities(again.Lookup("a")))
+		}
+	}
+
+	invalid := []struct {
+		text string
+		at   errorAt
+	}{
+		//    %s与引号之间不能有空白
+		{"a = %s \"x\"\r\n", errorAt{1, 7}},
+		{"a = %s\r\n", errorAt{1, 7}},
+		{"a = %i\"x\r\n", errorAt{1, 9}},
+	}
+	for _, test := range invalid {
+		_, err := NewParser(strings.NewReader(test.text)).Parse()
+		var errs ErrorList
+		if !errors.As(err, &errs) || len(errs) != 1 || positionOf(t, errs[0]) != test.at {
+			t.Errorf("%q: err = %v, want an error at %v", test.text, err, test.at)
+		}
+	}
+}
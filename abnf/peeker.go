@@ -12,6 +12,12 @@ const (
 type Peeker struct {
 	pos  int
 	line int
+
+	//已经读入的字节数，即下一个字节从0开始的偏移
+	offset int
+
+	//上一个读入的字节是否是CR，用来把CRLF当作一次换行
+	lastCR bool
 	/**
 	 * The underlying stream.
 	 */
@@ -53,14 +59,26 @@ func (this *Peeker) GetLine() int {
 	return this.line
 }
 
-//when read LF, set pos = 1
-//when read CR, line++
+func (this *Peeker) GetOffset() int {
+	return this.offset
+}
+
+//CRLF、单独的LF、单独的CR各算一次换行：读到CR或者不在CR之后的LF时line++, pos = 1。
+//无论是否是宽松模式都这样计算行号，是否接受单独的LF和CR由Parser决定
 func (this *Peeker) UpdatePosition(value byte) {
-	if value == 0x0D {
+	this.offset++
+	lastCR := this.lastCR
+	this.lastCR = value == 0x0D
+	switch {
+	case value == 0x0D:
+		this.line++
 		this.pos = 1
-	} else if value == 0x0A {
+	case value == 0x0A && lastCR:
+		//CRLF中的LF，换行已经在读到CR时计算过了
+	case value == 0x0A:
 		this.line++
-	} else {
+		this.pos = 1
+	default:
 		this.pos++
 	}
 }
@@ -96,17 +114,18 @@ func (this *Peeker) Peek(depth int) int {
 	}
 
 	// does more data need to be read?
-	if depth >= this.peekLength {
+	// a single Read may return fewer bytes than requested, so keep reading
+	// until the requested depth is available or the stream is exhausted.
+	for depth >= this.peekLength {
 		offset := this.peekLength
 		length := (depth - this.peekLength) + 1
-		_, IOException := this.stream.Read(this.peekBytes[offset:offset+length])
-		
-		if IOException != nil{
+		readLength, IOException := this.stream.Read(this.peekBytes[offset : offset+length])
+		this.peekLength += readLength
+
+		if IOException != nil && depth >= this.peekLength {
 			//fmt.Printf("Peek(%d): peekLength=%d, length=%d, readLength=%d, err=%s\n", depth, this.peekLength, length, readLength, IOException);
-			return PEEKER_EOF;//panic(IOException.Error())
+			return PEEKER_EOF //panic(IOException.Error())
 		}
-
-		this.peekLength = depth + 1
 	}
 
 	return int(this.peekBytes[depth])
@@ -121,7 +140,7 @@ func (this *Peeker) Peek(depth int) int {
 func (this *Peeker) Read() int {
 	if this.peekLength == 0 {
 		var value [1]byte
-		_, IOException := this.stream.Read(value[:])
+		_, IOException := io.ReadFull(this.stream, value[:])
 		if IOException != nil{
 			//fmt.Printf("Read(): peekLength=%d, length=%d, readLength=%d, err=%s\n", this.peekLength, 1, readLength, IOException);
 			return PEEKER_EOF;//panic(IOException.Error())
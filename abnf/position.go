@@ -0,0 +1,35 @@
+package abnf
+
+import (
+	"strconv"
+)
+
+//    文法文件中的一个位置：行号和列号从1开始，Offset是从0开始的字节偏移
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (this Position) String() string {
+	return strconv.Itoa(this.Line) + ":" + strconv.Itoa(this.Column)
+}
+
+//    语法树节点在文法文件中的范围，Start是第一个字节的位置，End是最后一个字节之后的位置
+type Span struct {
+	start Position
+	end   Position
+}
+
+func (this *Span) GetStart() Position {
+	return this.start
+}
+
+func (this *Span) GetEnd() Position {
+	return this.end
+}
+
+func (this *Span) SetSpan(start, end Position) {
+	this.start = start
+	this.end = end
+}
@@ -0,0 +1,285 @@
+package abnf
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+//    把规则连同注释写回ABNF文本。
+//    注释之后必须换行，续行以空格缩进，使写出的文本可以被Parser重新解析。
+//    NewFormatter()返回的Printer输出RFC 5234风格的规范格式：
+//    对齐等号、折行过长的规则、统一num-val的大小写
+type Printer struct {
+	buffer bytes.Buffer
+
+	//    行结束符
+	newline string
+
+	//    是否在同一段（不被空行或注释行隔开的连续规则）中对齐等号
+	align bool
+
+	//    对齐等号时是否跨越空行和注释行，在整个文件中对齐（RFC 5234的风格）
+	alignFile bool
+
+	//    每行的最大宽度，超过则在"/"或者元素之间折行，0表示不折行
+	width int
+
+	//    是否输出规范形式：num-val统一大小写，合并连续的空行，去掉注释末尾的空白
+	canonical bool
+
+	//    对齐时规则名所占的宽度
+	nameWidth map[*Rule]int
+
+	//    当前规则续行的缩进
+	indent string
+
+	//    当前行已经写出的字符数
+	column int
+
+	//    当前是否在一行的开始（缩进之后），此时省略元素之间的分隔空格
+	lineStart bool
+
+	//    只写出元素而不写出注释，用于计算折行所需的长度（见flat）
+	bare bool
+}
+
+func NewPrinter() *Printer {
+	this := &Printer{}
+	this.newline = "\r\n"
+	this.nameWidth = make(map[*Rule]int)
+	return this
+}
+
+//    规范格式的Printer，类似于gofmt
+func NewFormatter() *Printer {
+	this := NewPrinter()
+	this.align = true
+	this.alignFile = true
+	this.width = 72
+	this.canonical = true
+	return this
+}
+
+func (this *Printer) SetNewline(newline string) {
+	this.newline = newline
+}
+
+func (this *Printer) SetAlign(align bool) {
+	this.align = align
+}
+
+//    alignFile为true时在整个文件中对齐等号，只在SetAlign(true)时有效
+func (this *Printer) SetAlignFile(alignFile bool) {
+	this.alignFile = alignFile
+}
+
+func (this *Printer) SetWidth(width int) {
+	this.width = width
+}
+
+func (this *Printer) SetCanonical(canonical bool) {
+	this.canonical = canonical
+}
+
+//    写出所有规则，trailingLines是最后一条规则之后的注释行和空行（见Parser.GetTrailingLines）
+func (this *Printer) Print(writer io.Writer, grammar *Grammar, trailingLines []string) error {
+	this.buffer.Reset()
+	this.measure(grammar)
+	for _, rule := range grammar.GetRules() {
+		this.rule(rule)
+	}
+	this.lines(trailingLines)
+	_, err := writer.Write(this.buffer.Bytes())
+	return err
+}
+
+//    返回一条规则（包括它前面的注释行和空行）的文本
+func (this *Printer) PrintRule(rule *Rule) string {
+	this.buffer.Reset()
+	this.rule(rule)
+	return this.buffer.String()
+}
+
+//    对齐等号：被空行或注释行隔开的每一段规则，规则名按该段中最长的规则名补齐；
+//    alignFile为true时所有规则按整个文件中最长的规则名补齐
+func (this *Printer) measure(grammar *Grammar) {
+	this.nameWidth = make(map[*Rule]int)
+	if !this.align {
+		return
+	}
+	block := make([]*Rule, 0)
+	flush := func() {
+		width := 0
+		for _, rule := range block {
+			if len(rule.GetRuleName().String()) > width {
+				width = len(rule.GetRuleName().String())
+			}
+		}
+		for _, rule := range block {
+			this.nameWidth[rule] = width
+		}
+		block = block[:0]
+	}
+	for _, rule := range grammar.GetRules() {
+		if len(rule.GetLeadingLines()) > 0 && !this.alignFile {
+			flush()
+		}
+		block = append(block, rule)
+	}
+	flush()
+}
+
+func (this *Printer) lines(lines []string) {
+	for i, line := range lines {
+		if this.canonical {
+			line = strings.TrimRight(line, " \t")
+			//    合并连续的空行
+			if line == "" && i > 0 && strings.TrimRight(lines[i-1], " \t") == "" {
+				continue
+			}
+		}
+		this.buffer.WriteString(line)
+		this.buffer.WriteString(this.newline)
+	}
+	this.column = 0
+}
+
+func (this *Printer) write(s string) {
+	this.buffer.WriteString(s)
+	this.column += len(s)
+	this.lineStart = false
+}
+
+//    换行并缩进到当前规则的续行位置
+func (this *Printer) wrap() {
+	this.buffer.WriteString(this.newline)
+	this.buffer.WriteString(this.indent)
+	this.column = len(this.indent)
+	this.lineStart = true
+}
+
+//    元素之间的分隔空格，在行首（缩进之后）省略
+func (this *Printer) space() {
+	if !this.lineStart {
+		this.write(" ")
+	}
+}
+
+//    如果接下来要写的长度为length的文本会超出行宽，则先折行
+func (this *Printer) fit(length int) {
+	if this.width > 0 && !this.lineStart && this.column+length > this.width {
+		this.wrap()
+	}
+}
+
+//    不折行时一段元素的长度，不计其中的注释：注释写在行尾，不应该因为注释而折行
+func (this *Printer) flat(print func(printer *Printer)) int {
+	printer := NewPrinter()
+	printer.canonical = this.canonical
+	printer.bare = true
+	print(printer)
+	return printer.buffer.Len()
+}
+
+func (this *Printer) comment(comment string) string {
+	if this.canonical {
+		return strings.TrimRight(comment, " \t")
+	}
+	return comment
+}
+
+//    写出注释，每条注释之后换行并缩进
+func (this *Printer) comments(comments []string) {
+	if this.bare {
+		return
+	}
+	for _, comment := range comments {
+		this.space()
+		this.write(this.comment(comment))
+		this.wrap()
+	}
+}
+
+func (this *Printer) rule(rule *Rule) {
+	this.lines(rule.GetLeadingLines())
+	name := rule.GetRuleName().String()
+	if width, present := this.nameWidth[rule]; present && width > len(name) {
+		name += strings.Repeat(" ", width-len(name))
+	}
+	prefix := name + " " + rule.GetDefinedAs() + " "
+	this.indent = strings.Repeat(" ", len(prefix))
+	this.column = 0
+	this.write(prefix)
+	this.lineStart = true
+	this.alternation(rule.GetElements().GetAlternation())
+
+	//    规则本身的注释，第一条写在行尾，其余的各占一个续行
+	for i, comment := range rule.GetComments() {
+		if i > 0 {
+			this.wrap()
+		}
+		this.space()
+		this.write(this.comment(comment))
+	}
+	this.buffer.WriteString(this.newline)
+	this.column = 0
+	this.lineStart = true
+}
+
+func (this *Printer) alternation(alternation *Alternation) {
+	for i, concatenation := range alternation.GetConcatenations() {
+		if i > 0 {
+			//    过长时在"/"之前折行，续行以"/"开始
+			this.fit(3 + this.flat(func(printer *Printer) { printer.concatenation(concatenation) }))
+			this.space()
+			this.write("/ ")
+		}
+		this.concatenation(concatenation)
+	}
+}
+
+func (this *Printer) concatenation(concatenation *Concatenation) {
+	for i, repetition := range concatenation.GetRepetitions() {
+		if i > 0 {
+			this.fit(1 + this.flat(func(printer *Printer) { printer.repetition(repetition) }))
+			this.space()
+		}
+		this.repetition(repetition)
+	}
+}
+
+func (this *Printer) repetition(repetition *Repetition) {
+	if repetition.GetRepeat() != nil {
+		this.write(repetition.GetRepeat().String())
+	}
+	this.element(repetition.GetElement())
+	this.comments(repetition.GetComments())
+}
+
+func (this *Printer) element(element Element) {
+	switch element.GetElementType() {
+	case ELEMENT_GROUP:
+		group := element.(*Group)
+		this.write("(")
+		this.comments(group.GetComments())
+		this.alternation(group.GetAlternation())
+		this.write(")")
+	case ELEMENT_OPTION:
+		option := element.(*Option)
+		this.write("[")
+		this.comments(option.GetComments())
+		this.alternation(option.GetAlternation())
+		this.write("]")
+	case ELEMENT_PROSEVAL:
+		this.write("<" + element.String() + ">")
+	case ELEMENT_NUMVAL:
+		if this.canonical {
+			this.write(element.(*NumVal).CanonicalString())
+		} else {
+			this.write(element.String())
+		}
+	default:
+		this.write(element.String())
+	}
+}
@@ -0,0 +1,168 @@
+package abnf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+//    RFC 5234第4节的一段
+const rfc5234Excerpt = `rulelist       =  1*( rule / (*c-wsp c-nl) )
+
+rule           =  rulename defined-as elements c-nl
+                       ; continues if next line starts
+                       ;  with white space
+
+rulename       =  ALPHA *(ALPHA / DIGIT / "-")
+
+defined-as     =  *c-wsp ("=" / "=/") *c-wsp
+                       ; basic rules definition and
+                       ;  incremental alternatives
+
+elements       =  alternation *c-wsp
+
+c-wsp          =  WSP / (c-nl WSP)
+
+c-nl           =  comment / CRLF
+                       ; comment or newline
+
+comment        =  ";" *(WSP / VCHAR) CRLF
+
+char-val       =  DQUOTE *(%x20-21 / %x23-7E) DQUOTE
+                       ; quoted string of SP and VCHAR
+                       ;  without DQUOTE
+
+bin-val        =  "b" 1*BIT
+                  [ 1*("." 1*BIT) / ("-" 1*BIT) ]
+                       ; series of concatenated bit values
+                       ;  or single ONEOF range
+`
+
+const rfc5234Formatted = `rulelist   = 1*(rule / (*c-wsp c-nl))
+
+rule       = rulename defined-as elements c-nl ; continues if next line starts
+             ;  with white space
+
+rulename   = ALPHA *(ALPHA / DIGIT / "-")
+
+defined-as = *c-wsp ("=" / "=/") *c-wsp ; basic rules definition and
+             ;  incremental alternatives
+
+elements   = alternation *c-wsp
+
+c-wsp      = WSP / (c-nl WSP)
+
+c-nl       = comment / CRLF ; comment or newline
+
+comment    = ";" *(WSP / VCHAR) CRLF
+
+char-val   = DQUOTE *(%x20-21 / %x23-7E) DQUOTE ; quoted string of SP and VCHAR
+             ;  without DQUOTE
+
+bin-val    = "b" 1*BIT [1*("." 1*BIT) / ("-" 1*BIT)] ; series of concatenated bit values
+             ;  or single ONEOF range
+`
+
+func format(t *testing.T, printer *Printer, text string) string {
+	p := NewParser(strings.NewReader(strings.Replace(text, "\n", "\r\n", -1)))
+	grammar, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	printer.SetNewline("\n")
+	var buffer bytes.Buffer
+	if err := printer.Print(&buffer, grammar, p.GetTrailingLines()); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	return buffer.String()
+}
+
+func TestFormatterRFC5234(t *testing.T) {
+	formatted := format(t, NewFormatter(), rfc5234Excerpt)
+	if formatted != rfc5234Formatted {
+		t.Errorf("got:\n%s\nwant:\n%s", formatted, rfc5234Formatted)
+	}
+	//    格式化的结果再次格式化不变
+	if again := format(t, NewFormatter(), formatted); again != formatted {
+		t.Errorf("formatting is not stable:\n%s", again)
+	}
+}
+
+func TestFormatterAlignBlock(t *testing.T) {
+	printer := NewFormatter()
+	printer.SetAlignFile(false)
+	formatted := format(t, printer, "a = \"x\"\nlong-name = a\n\n; next\nbb = a\nc = a\n")
+	want := "a         = \"x\"\nlong-name = a\n\n; next\nbb = a\nc  = a\n"
+	if formatted != want {
+		t.Errorf("got:\n%s\nwant:\n%s", formatted, want)
+	}
+}
+
+func TestFormatterWrapIgnoresComments(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		//    最后一个元素之后的注释不引起折行
+		{
+			"a = \"b\" 1*BIT ; a comment that goes far beyond the width of the line\n",
+			"a = \"b\" 1*BIT ; a comment that goes far beyond the width of the line\n",
+		},
+		//    元素之后的注释写在行尾，下一个元素另起一行
+		{
+			"a = \"b\" ; a comment that goes far beyond the width of the line\n  1*BIT\n",
+			"a = \"b\" ; a comment that goes far beyond the width of the line\n    1*BIT\n",
+		},
+		//    元素本身超过行宽时仍然折行
+		{
+			"a = \"bbbbbbbbbbbbbbbbbbbb\" \"cccccccccccccccccccc\" \"dddddddddddddddddddddddd\" ; c\n",
+			"a = \"bbbbbbbbbbbbbbbbbbbb\" \"cccccccccccccccccccc\"\n    \"dddddddddddddddddddddddd\" ; c\n",
+		},
+	}
+	for _, test := range tests {
+		if formatted := format(t, NewFormatter(), test.text); formatted != test.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", test.text, formatted, test.want)
+		}
+	}
+}
+
+func TestPrinterIncrementalComments(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{
+			"a = \"x\" ; base\n; about inc\na =/ \"y\" ; inc\n",
+			"a = \"x\" ; base\n    ; about inc\n    / \"y\" ; inc\n",
+		},
+		//    基本定义出现在增量定义之后
+		{
+			"; about inc\na =/ \"y\" ; inc\n; about base\na = \"x\" ; base\n",
+			"; about inc\n; about base\na = \"x\" ; base\n    / \"y\" ; inc\n",
+		},
+	}
+	for _, test := range tests {
+		formatted := format(t, NewFormatter(), test.text)
+		if formatted != test.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", test.text, formatted, test.want)
+		}
+		if again := format(t, NewFormatter(), formatted); again != formatted {
+			t.Errorf("formatting is not stable:\n%s", again)
+		}
+	}
+}
+
+func TestPrinterCaseSensitivity(t *testing.T) {
+	//    %S和%I统一写成小写，没有前缀的字符串保持没有前缀
+	text := "a = %S\"aB\" / %I\"c\" / \"d\" / %s\"\"\n"
+	want := "a = %s\"aB\" / %i\"c\" / \"d\" / %s\"\"\n"
+	for _, printer := range []*Printer{NewPrinter(), NewFormatter()} {
+		formatted := format(t, printer, text)
+		if formatted != want {
+			t.Errorf("got %q, want %q", formatted, want)
+		}
+		if again := format(t, printer, formatted); again != formatted {
+			t.Errorf("printing is not stable: %q", again)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 //                          last resort
 
 type ProseVal struct { //implements Element {
+	Span
 	value string
 }
 
@@ -32,14 +33,14 @@ func (this *ProseVal) GetDependentRuleNames() Set_RuleName {
 	return make(Set_RuleName)
 }
 
-func (this *ProseVal) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *ProseVal) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *ProseVal) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
+func (this *ProseVal) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
 	if len(this.value) == 0 {
 		startState.AddTransitEpsilon(acceptingState)
 		return
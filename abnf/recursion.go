@@ -0,0 +1,309 @@
+package abnf
+
+import (
+	"errors"
+)
+
+//    直接递归的方向：尾递归（右线性）或者头递归（左线性）
+type RecursionSide int
+
+const (
+	RECURSION_RIGHT RecursionSide = iota
+	RECURSION_LEFT
+)
+
+//    把直接递归的规则改写为不含递归的等价形式。
+//    右线性的规则R = B / P R（R只出现在各个候选项的末尾）匹配的语言是*P B，
+//    左线性的规则R = B / R P（R只出现在各个候选项的开头）匹配的语言是B *P，
+//    其中B和P中都不再引用R。改写的过程就是从规则中分离出B和P
+type recursionRewriter struct {
+	key  string
+	side RecursionSide
+
+	//    无法改写的引用，以及原因是否是引用位于重复（例如*R）之中
+	failure  *RuleName
+	repeated bool
+}
+
+//    返回node中第一处对规则的引用，没有引用时返回nil
+func (this *recursionRewriter) refersTo(node Node) *RuleName {
+	var found *RuleName
+	Inspect(node, func(node Node) bool {
+		if ruleName, ok := node.(*RuleName); ok && found == nil && ruleName.GetKey() == this.key {
+			found = ruleName
+		}
+		return found == nil
+	})
+	return found
+}
+
+func (this *recursionRewriter) fail(ruleName *RuleName, repeated bool) ([]*Concatenation, []*Concatenation, bool) {
+	this.failure = ruleName
+	this.repeated = repeated
+	return nil, nil, false
+}
+
+//    只匹配空串的连接
+func epsilon() *Concatenation {
+	concatenation := NewConcatenation()
+	concatenation.AddRepetition(NewRepetition(nil, NewCharVal("")))
+	return concatenation
+}
+
+func isEpsilon(concatenation *Concatenation) bool {
+	repetitions := concatenation.GetRepetitions()
+	if len(repetitions) != 1 || repetitions[0].GetRepeat() != nil {
+		return false
+	}
+	charVal, ok := repetitions[0].GetElement().(*CharVal)
+	return ok && charVal.GetValue() == ""
+}
+
+//    把不含递归的元素others接到每个候选项的前面（右线性）或者后面（左线性）
+func (this *recursionRewriter) attach(others []*Repetition, parts []*Concatenation) []*Concatenation {
+	if len(others) == 0 {
+		return parts
+	}
+	result := make([]*Concatenation, 0, len(parts))
+	for _, part := range parts {
+		concatenation := NewConcatenation()
+		if isEpsilon(part) {
+			concatenation.SetRepetitions(others)
+		} else if this.side == RECURSION_RIGHT {
+			concatenation.SetRepetitions(append(append([]*Repetition{}, others...), part.GetRepetitions()...))
+		} else {
+			concatenation.SetRepetitions(append(append([]*Repetition{}, part.GetRepetitions()...), others...))
+		}
+		result = append(result, concatenation)
+	}
+	return result
+}
+
+//    分离出候选项中的B和P，失败时返回false
+func (this *recursionRewriter) alternation(alternation *Alternation) ([]*Concatenation, []*Concatenation, bool) {
+	base := make([]*Concatenation, 0)
+	step := make([]*Concatenation, 0)
+	for _, concatenation := range alternation.GetConcatenations() {
+		b, s, ok := this.concatenation(concatenation)
+		if !ok {
+			return nil, nil, false
+		}
+		base = append(base, b...)
+		step = append(step, s...)
+	}
+	return base, step, true
+}
+
+func (this *recursionRewriter) concatenation(concatenation *Concatenation) ([]*Concatenation, []*Concatenation, bool) {
+	repetitions := concatenation.GetRepetitions()
+	//    递归的引用只能出现在末尾（右线性）或者开头（左线性）的元素中
+	index := len(repetitions) - 1
+	if this.side == RECURSION_LEFT {
+		index = 0
+	}
+	others := make([]*Repetition, 0, len(repetitions)-1)
+	for i, repetition := range repetitions {
+		if i == index {
+			continue
+		}
+		if ruleName := this.refersTo(repetition); ruleName != nil {
+			return this.fail(ruleName, false)
+		}
+		others = append(others, repetition)
+	}
+	if this.refersTo(repetitions[index]) == nil {
+		return []*Concatenation{concatenation}, []*Concatenation{}, true
+	}
+	base, step, ok := this.repetition(repetitions[index])
+	if !ok {
+		return nil, nil, false
+	}
+	return this.attach(others, base), this.attach(others, step), true
+}
+
+func (this *recursionRewriter) repetition(repetition *Repetition) ([]*Concatenation, []*Concatenation, bool) {
+	if repeat := repetition.GetRepeat(); repeat != nil && !isOnce(repeat) {
+		return this.fail(this.refersTo(repetition), true)
+	}
+	switch element := repetition.GetElement().(type) {
+	case *RuleName:
+		//    R本身：B为空，P为空串
+		return []*Concatenation{}, []*Concatenation{epsilon()}, true
+	case *Group:
+		return this.alternation(element.GetAlternation())
+	case *Option:
+		base, step, ok := this.alternation(element.GetAlternation())
+		if !ok {
+			return nil, nil, false
+		}
+		return append(base, epsilon()), step, true
+	default:
+		//    其他元素中不会有规则引用
+		panic("Unexpected recursive element " + element.String())
+	}
+}
+
+//    恰好出现一次的repeat：1*1，或者不带星号的1（Parser把固定次数n表示为NewRepeat(n, -1, false)）
+func isOnce(repeat *Repeat) bool {
+	if repeat.IsStarred() {
+		return repeat.GetMin() == 1 && repeat.GetMax() == 1
+	}
+	return repeat.GetMin() == 1
+}
+
+func alternationOf(concatenations []*Concatenation) *Alternation {
+	alternation := NewAlternation()
+	alternation.SetConcatenations(concatenations)
+	return alternation
+}
+
+//    把直接递归的规则改写为用重复表示的等价规则，使GetNFAStates可以生成NFA：
+//        list = item [ "," list ]    改写为    list = *(item ",") (item)
+//        list = item / list "," item 改写为    list = (item) *("," item)
+//    规则不引用自己时原样返回。规则是自嵌入的（例如R = "(" R ")"）、
+//    同时有头递归和尾递归、递归引用位于重复之中，或者每个候选项都引用自己时无法改写，
+//    返回的error说明了原因
+func RewriteRecursion(rule *Rule) (*Rule, error) {
+	alternation, err := rewriteRecursion(rule.GetRuleName(), rule.GetElements().GetAlternation())
+	if err != nil {
+		return nil, err
+	}
+	return rewrittenRule(rule, alternation), nil
+}
+
+//    把互相递归的一组规则（依赖图中的一个强连通分量）改写为不含递归的等价规则。
+//    从最后一条规则开始，先把它自身的递归改写为重复，再把它的定义代入前面的规则，
+//    直到第一条规则只引用自己；然后按相反的顺序把改写后的定义代回后面的规则。
+//    只要每一步都是头递归或尾递归就可以改写，否则返回的error说明了原因
+func RewriteComponent(component []*Rule) ([]*Rule, error) {
+	alternations := make([]*Alternation, len(component))
+	for i, rule := range component {
+		alternations[i] = rule.GetElements().GetAlternation()
+	}
+
+	//    消去：改写后的alternations[i]只引用component[0..i-1]
+	for i := len(component) - 1; i >= 0; i-- {
+		alternation, err := rewriteRecursion(component[i].GetRuleName(), alternations[i])
+		if err != nil {
+			return nil, err
+		}
+		alternations[i] = alternation
+		for j := 0; j < i; j++ {
+			alternations[j] = substitute(alternations[j], component[i].GetRuleName().GetKey(), alternation)
+		}
+	}
+	//    代回：alternations[0]已经不含递归
+	for i := 1; i < len(component); i++ {
+		for j := 0; j < i; j++ {
+			alternations[i] = substitute(alternations[i], component[j].GetRuleName().GetKey(), alternations[j])
+		}
+	}
+
+	rules := make([]*Rule, len(component))
+	for i, rule := range component {
+		rules[i] = rewrittenRule(rule, alternations[i])
+	}
+	return rules, nil
+}
+
+func rewrittenRule(rule *Rule, alternation *Alternation) *Rule {
+	rewritten := NewRule(rule.GetRuleName(), rule.GetDefinedAs(), NewElements(alternation))
+	rewritten.SetSpan(rule.GetStart(), rule.GetEnd())
+	rewritten.SetFileName(rule.GetFileName())
+	return rewritten
+}
+
+//    改写alternation中对ruleName的直接递归，不引用ruleName时原样返回
+func rewriteRecursion(ruleName *RuleName, alternation *Alternation) (*Alternation, error) {
+	name := ruleName.String()
+	right := &recursionRewriter{key: ruleName.GetKey(), side: RECURSION_RIGHT}
+	if right.refersTo(alternation) == nil {
+		return alternation, nil
+	}
+
+	side := RECURSION_RIGHT
+	base, step, ok := right.alternation(alternation)
+	if !ok {
+		left := &recursionRewriter{key: right.key, side: RECURSION_LEFT}
+		side = RECURSION_LEFT
+		base, step, ok = left.alternation(alternation)
+		if !ok {
+			switch {
+			case right.repeated:
+				return nil, errors.New(name + " is not linear: the reference to itself at " + right.failure.GetStart().String() + " is inside a repetition.")
+			case left.repeated:
+				return nil, errors.New(name + " is not linear: the reference to itself at " + left.failure.GetStart().String() + " is inside a repetition.")
+			case right.failure == left.failure:
+				return nil, errors.New(name + " is self-embedding: the reference to itself at " + right.failure.GetStart().String() + " has other elements both before and after it.")
+			default:
+				return nil, errors.New(name + " mixes right and left recursion: the reference to itself at " + right.failure.GetStart().String() + " is not at the end of its alternative, and the one at " + left.failure.GetStart().String() + " is not at the beginning.")
+			}
+		}
+	}
+	if len(base) == 0 {
+		return nil, errors.New(name + " can not match any finite input: every alternative refers to " + name + " itself.")
+	}
+
+	//    右线性：*P B，左线性：B *P
+	concatenation := NewConcatenation()
+	baseRepetition := NewRepetition(nil, NewGroup(alternationOf(base)))
+	if len(step) == 0 {
+		concatenation.AddRepetition(baseRepetition)
+	} else {
+		stepRepetition := NewRepetition(NewRepeat(0, -1, true), NewGroup(alternationOf(step)))
+		if side == RECURSION_RIGHT {
+			concatenation.AddRepetition(stepRepetition)
+			concatenation.AddRepetition(baseRepetition)
+		} else {
+			concatenation.AddRepetition(baseRepetition)
+			concatenation.AddRepetition(stepRepetition)
+		}
+	}
+	return alternationOf([]*Concatenation{concatenation}), nil
+}
+
+//    返回把alternation中对规则key的引用替换为(replacement)之后的副本，
+//    不含引用的子树不复制，整个alternation都不含引用时返回alternation本身
+func substitute(alternation *Alternation, key string, replacement *Alternation) *Alternation {
+	changed := false
+	concatenations := make([]*Concatenation, 0, len(alternation.GetConcatenations()))
+	for _, concatenation := range alternation.GetConcatenations() {
+		replaced := false
+		repetitions := make([]*Repetition, 0, len(concatenation.GetRepetitions()))
+		for _, repetition := range concatenation.GetRepetitions() {
+			element := repetition.GetElement()
+			switch e := element.(type) {
+			case *RuleName:
+				if e.GetKey() == key {
+					element = NewGroup(replacement)
+				}
+			case *Group:
+				if substituted := substitute(e.GetAlternation(), key, replacement); substituted != e.GetAlternation() {
+					element = NewGroup(substituted)
+				}
+			case *Option:
+				if substituted := substitute(e.GetAlternation(), key, replacement); substituted != e.GetAlternation() {
+					element = NewOption(substituted)
+				}
+			}
+			if element == repetition.GetElement() {
+				repetitions = append(repetitions, repetition)
+			} else {
+				repetitions = append(repetitions, NewRepetition(repetition.GetRepeat(), element))
+				replaced = true
+			}
+		}
+		if !replaced {
+			concatenations = append(concatenations, concatenation)
+			continue
+		}
+		changed = true
+		substituted := NewConcatenation()
+		substituted.SetRepetitions(repetitions)
+		concatenations = append(concatenations, substituted)
+	}
+	if !changed {
+		return alternation
+	}
+	return alternationOf(concatenations)
+}
@@ -0,0 +1,115 @@
+package abnf
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseRules(t *testing.T, text string) []*Rule {
+	grammar, err := NewParser(strings.NewReader(strings.Replace(text, "\n", "\r\n", -1))).Parse()
+	if err != nil {
+		t.Fatalf("%q: %v", text, err)
+	}
+	return grammar.GetRules()
+}
+
+func TestRewriteRecursion(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+		err  string
+	}{
+		{"not recursive", "a = \"x\"\n", "a = \"x\"", ""},
+		{"right-linear", "list = item [\",\" list]\n", "list = *(item \",\") (item)", ""},
+		{"right-linear alternatives", "a = \"x\" / \"y\" a\n", "a = *(\"y\") (\"x\")", ""},
+		{"right-linear fixed repeat", "a = \"x\" / \"y\" 1a\n", "a = *(\"y\") (\"x\")", ""},
+		{"right-linear 1*1", "a = \"x\" / \"y\" 1*1a\n", "a = *(\"y\") (\"x\")", ""},
+		{"fixed repeat", "r = \"x\" / \"y\" 2r\n", "",
+			"r is not linear: the reference to itself at 1:16 is inside a repetition."},
+		{"left-linear", "list = item / list \",\" item\n", "list = (item) *(\",\" item)", ""},
+		{"self-embedding", "p = \"(\" p \")\" / \"x\"\n", "",
+			"p is self-embedding: the reference to itself at 1:9 has other elements both before and after it."},
+		{"repetition", "r = *r \"x\"\n", "",
+			"r is not linear: the reference to itself at 1:6 is inside a repetition."},
+		{"bounded repetition", "r = \"x\" 1*2r\n", "",
+			"r is not linear: the reference to itself at 1:12 is inside a repetition."},
+		{"right and left", "m = \"a\" m / m \"b\" / \"c\"\n", "",
+			"m mixes right and left recursion: the reference to itself at 1:13 is not at the end of its alternative, and the one at 1:9 is not at the beginning."},
+		{"infinite", "r = \"x\" r\n", "",
+			"r can not match any finite input: every alternative refers to r itself."},
+	}
+	for _, test := range tests {
+		rule, err := RewriteRecursion(parseRules(t, test.text)[0])
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if rule.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, rule.String(), test.want)
+		}
+	}
+}
+
+func TestRewriteComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+		err  string
+	}{
+		{"mutual right-linear", "a = \"x\" b / \"y\"\nb = \"z\" a\n",
+			[]string{"a = *(\"x\" \"z\") (\"y\")", "b = \"z\" (*(\"x\" \"z\") (\"y\"))"}, ""},
+		{"mutual left-linear", "a = b \"x\" / \"y\"\nb = a \"z\" / \"w\"\n",
+			[]string{"a = (\"w\" \"x\"/\"y\") *(\"z\" \"x\")", "b = ((\"w\" \"x\"/\"y\") *(\"z\" \"x\")) \"z\"/\"w\""}, ""},
+		{"mutual self-embedding", "a = \"(\" b / \"y\"\nb = a \")\"\n", nil,
+			"a is self-embedding: the reference to itself at 2:5 has other elements both before and after it."},
+		{"mutual repetition", "a = \"x\" *b / \"y\"\nb = \"z\" a\n", nil,
+			"a is not linear: the reference to itself at 2:9 is inside a repetition."},
+	}
+	for _, test := range tests {
+		rules, err := RewriteComponent(parseRules(t, test.text))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for i, rule := range rules {
+			if rule.String() != test.want[i] {
+				t.Errorf("%s: got %s, want %s", test.name, rule.String(), test.want[i])
+			}
+		}
+	}
+}
+
+func TestSubstituteSharesUnchangedSubtrees(t *testing.T) {
+	rules := parseRules(t, "a = \"x\" / (\"y\" [b]) / c\nb = \"z\"\n")
+	alternation := rules[0].GetElements().GetAlternation()
+	replacement := rules[1].GetElements().GetAlternation()
+
+	if substitute(alternation, "d", replacement) != alternation {
+		t.Errorf("alternation without references is copied")
+	}
+	substituted := substitute(alternation, "b", replacement)
+	if substituted.String() != "\"x\"/(\"y\" [(\"z\")])/c" {
+		t.Errorf("got %s", substituted.String())
+	}
+	before, after := alternation.GetConcatenations(), substituted.GetConcatenations()
+	if after[0] != before[0] || after[2] != before[2] {
+		t.Errorf("concatenations without references are copied")
+	}
+	if after[1] == before[1] {
+		t.Errorf("concatenation with a reference is not copied")
+	}
+}
@@ -28,6 +28,11 @@ func (this *Repeat) GetMax() int {
 	return this.max
 }
 
+//    是否带有星号，不带星号的repeat（例如3rule）表示固定的重复次数，此时只有min有意义
+func (this *Repeat) IsStarred() bool {
+	return this.starred
+}
+
 func (this *Repeat) String() string {
 	var s bytes.Buffer
 	if this.starred {
@@ -6,6 +6,8 @@ import (
 
 // repetition     =  [repeat] element
 type Repetition struct {
+	Span
+	Trivia
 	repeat  *Repeat
 	element Element
 }
@@ -17,6 +19,22 @@ func NewRepetition(repeat *Repeat, element Element) *Repetition {
 	return this
 }
 
+func (this *Repetition) GetRepeat() *Repeat {
+	return this.repeat
+}
+
+func (this *Repetition) GetElement() Element {
+	return this.element
+}
+
+func (this *Repetition) SetRepeat(repeat *Repeat) {
+	this.repeat = repeat
+}
+
+func (this *Repetition) SetElement(element Element) {
+	this.element = element
+}
+
 func (this *Repetition) String() string {
 	if this.repeat != nil {
 		return this.repeat.String() + this.element.String()
@@ -29,16 +47,16 @@ func (this *Repetition) GetDependentRuleNames() Set_RuleName {
 	return this.element.GetDependentRuleNames()
 }
 
-func (this *Repetition) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *Repetition) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *Repetition) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
+func (this *Repetition) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
 	if this.repeat == nil {
-		this.element.GetNFAStates(startState, acceptingState, rules)
+		this.element.GetNFAStates(startState, acceptingState, context)
 		return
 	}
 
@@ -53,18 +71,18 @@ func (this *Repetition) GetNFAStates(startState, acceptingState *automata.NFASta
 		if min == 0 {
 			//              min == 0 && max == -1
 			startState.AddTransitEpsilon(acceptingState)
-			this.element.GetNFAStates(acceptingState, acceptingState, rules)
+			this.element.GetNFAStates(acceptingState, acceptingState, context)
 			return
 		} else {
 			//              min > 0 && max == -1
 			current := startState
 			for j := 0; j < min-1; j++ {
 				next := automata.NewNFAState()
-				this.element.GetNFAStates(current, next, rules)
+				this.element.GetNFAStates(current, next, context)
 				current = next
 			}
-			this.element.GetNFAStates(current, acceptingState, rules)
-			this.element.GetNFAStates(acceptingState, acceptingState, rules)
+			this.element.GetNFAStates(current, acceptingState, context)
+			this.element.GetNFAStates(acceptingState, acceptingState, context)
 			return
 		}
 	} else {
@@ -74,38 +92,38 @@ func (this *Repetition) GetNFAStates(startState, acceptingState *automata.NFASta
 			for j := 0; j < max-1; j++ {
 				current.AddTransitEpsilon(acceptingState)
 				next := automata.NewNFAState()
-				this.element.GetNFAStates(current, next, rules)
+				this.element.GetNFAStates(current, next, context)
 				current = next
 			}
 			current.AddTransitEpsilon(acceptingState)
-			this.element.GetNFAStates(current, acceptingState, rules)
+			this.element.GetNFAStates(current, acceptingState, context)
 			return
 		} else if min == max {
 			//              0 < min == max
 			current := startState
 			for j := 0; j < max-1; j++ {
 				next := automata.NewNFAState()
-				this.element.GetNFAStates(current, next, rules)
+				this.element.GetNFAStates(current, next, context)
 				current = next
 			}
-			this.element.GetNFAStates(current, acceptingState, rules)
+			this.element.GetNFAStates(current, acceptingState, context)
 			return
 		} else if min < max {
 			//              0 < min < max
 			current := startState
 			for j := 0; j < min; j++ {
 				next := automata.NewNFAState()
-				this.element.GetNFAStates(current, next, rules)
+				this.element.GetNFAStates(current, next, context)
 				current = next
 			}
 			for j := 0; j < max-min-1; j++ {
 				current.AddTransitEpsilon(acceptingState)
 				next := automata.NewNFAState()
-				this.element.GetNFAStates(current, next, rules)
+				this.element.GetNFAStates(current, next, context)
 				current = next
 			}
 			current.AddTransitEpsilon(acceptingState)
-			this.element.GetNFAStates(current, acceptingState, rules)
+			this.element.GetNFAStates(current, acceptingState, context)
 			return
 		} else {
 			panic("Max can not less than min")
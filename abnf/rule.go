@@ -3,9 +3,18 @@ package abnf
 import ()
 
 type Rule struct {
+	Span
+	//    规则内部及行尾的注释
+	Trivia
 	ruleName  *RuleName
 	definedAs string
 	elements  *Elements
+
+	//    规则之前的注释行和空行，注释行以分号开头，空行为空字符串
+	leadingLines []string
+
+	//    定义规则的文法文件，未知时为空字符串
+	fileName string
 }
 
 func NewRule(ruleName *RuleName, definedAs string, elements *Elements) *Rule {
@@ -32,6 +41,22 @@ func (this *Rule) GetElements() *Elements {
 	return this.elements
 }
 
+func (this *Rule) GetLeadingLines() []string {
+	return this.leadingLines
+}
+
+func (this *Rule) SetLeadingLines(leadingLines []string) {
+	this.leadingLines = leadingLines
+}
+
+func (this *Rule) GetFileName() string {
+	return this.fileName
+}
+
+func (this *Rule) SetFileName(fileName string) {
+	this.fileName = fileName
+}
+
 func (this *Rule) String() string{
 	return this.ruleName.String()+" "+this.definedAs+" "+this.elements.String();
 }
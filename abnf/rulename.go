@@ -2,50 +2,99 @@ package abnf
 
 import (
 	"GoABNF/automata"
+	"strconv"
+	"strings"
 )
 
 type RuleName struct { //implements Element {
+	Span
 	rulename string
 }
 
+//规则名集合，键值是规则名的规范形式（见CanonicalRuleName）
 type Set_RuleName map[string]*RuleName
 
+//RFC 5234规定规则名大小写不敏感，ALPHA与alpha是同一条规则，
+//所有按规则名查找的地方都使用折叠为小写的规范形式作为键值
+func CanonicalRuleName(rulename string) string {
+	return strings.ToLower(rulename)
+}
+
 func NewRuleName(rulename string) *RuleName {
 	this := &RuleName{}
 	this.rulename = rulename
 	return this
 }
 
+//返回规则名的原始写法
 func (this *RuleName) String() string {
 	return this.rulename
 }
 
+func (this *RuleName) SetRuleName(rulename string) {
+	this.rulename = rulename
+}
+
+//返回规则名的规范形式，用于比较和查找
+func (this *RuleName) GetKey() string {
+	return CanonicalRuleName(this.rulename)
+}
+
 func (this *RuleName) GetElementType() ElementType {
 	return ELEMENT_RULENAME
 }
 
 func (this *RuleName) GetDependentRuleNames() Set_RuleName {
 	ruleNames := make(Set_RuleName)
-	ruleNames[this.rulename] = this
+	ruleNames[this.GetKey()] = this
 	return ruleNames
 }
 
-func (this *RuleName) GetNFA(rules map[string]*Rule) *automata.NFA { //throws IllegalAbnfException {
+func (this *RuleName) GetNFA(context *NFAContext) *automata.NFA { //throws IllegalAbnfException {
 	startState := automata.NewNFAState()
 	acceptingState := automata.NewNFAState()
-	this.GetNFAStates(startState, acceptingState, rules)
+	this.GetNFAStates(startState, acceptingState, context)
 	return automata.NewNFA2(startState, acceptingState)
 }
 
-func (this *RuleName) GetNFAStates(startState, acceptingState *automata.NFAState, rules map[string]*Rule) {
-	if _, present := rules[this.String()]; !present {
-		panic("Fail to find the definition of " + this.String())
+func (this *RuleName) GetNFAStates(startState, acceptingState *automata.NFAState, context *NFAContext) {
+	rule := context.GetRule(this.String())
+	if rule == nil {
+		if context.GetMaxDepth() == NFA_NO_RECURSION {
+			panic("Fail to find the definition of " + this.String())
+		}
+		//    近似构造时不中断，记录下来并且不生成迁移
+		context.addUnhandled(this.GetKey(), this.String()+" is not defined.")
+		return
 	}
 
-	rule := rules[this.String()]
 	if rule.GetDefinedAs() == "=/" {
 		panic("Can not handle incremental definition while generating NFA.")
 	}
 
-	rule.GetElements().GetNFAStates(startState, acceptingState, rules)
+	//    递归的引用超过最大深度时不生成迁移，这条路径在NFA中走不通
+	key := this.GetKey()
+	if !context.enter(key) {
+		return
+	}
+	defer context.leave(key)
+
+	if context.GetMaxDepth() == NFA_NO_RECURSION || !context.IsRecursive(key) {
+		rule.GetElements().GetNFAStates(startState, acceptingState, context)
+		return
+	}
+
+	//    递归的规则展开中发生了截断时，检查规则在最大深度内是否还能匹配任何输入，
+	//    为此先在独立的开始和接受状态之间构造，再用epsilon迁移连接
+	start := automata.NewNFAState()
+	accepting := automata.NewNFAState()
+	truncations := context.truncations
+	rule.GetElements().GetNFAStates(start, accepting, context)
+	if context.truncations > truncations {
+		if _, reachable := automata.NewNFA2(start, accepting).GetStateSet()[accepting]; !reachable {
+			context.addUnhandled(key, this.String()+" matches no input when its recursion is unrolled to depth "+strconv.Itoa(context.GetMaxDepth())+".")
+		}
+	}
+	startState.AddTransitEpsilon(start)
+	accepting.AddTransitEpsilon(acceptingState)
 }
@@ -0,0 +1,21 @@
+package abnf
+
+import ()
+
+//    语法树节点上附带的注释，每条注释以分号开头，不含行结束符，
+//    用于把解析后的文法连同注释一起写回文件
+type Trivia struct {
+	comments []string
+}
+
+func (this *Trivia) GetComments() []string {
+	return this.comments
+}
+
+func (this *Trivia) AddComments(comments ...string) {
+	this.comments = append(this.comments, comments...)
+}
+
+func (this *Trivia) SetComments(comments []string) {
+	this.comments = comments
+}
@@ -0,0 +1,84 @@
+package abnf
+
+import (
+	"fmt"
+)
+
+//    语法树中的节点：Rule、Elements、Alternation、Concatenation、Repetition
+//    以及各种Element（RuleName、Group、Option、CharVal、NumVal、ProseVal）
+type Node interface {
+	String() string
+	GetStart() Position
+	GetEnd() Position
+}
+
+//    Walk遍历语法树时对每个节点调用Visit(node)。
+//    如果返回的Visitor w不为nil，则用w继续遍历node的每个子节点，最后调用w.Visit(nil)；
+//    返回nil表示不再遍历node的子节点
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+//    按深度优先的顺序遍历以node为根的语法树，子节点按照在规则中出现的顺序访问：
+//        Rule          -> RuleName, Elements
+//        Elements      -> Alternation
+//        Alternation   -> Concatenation ...
+//        Concatenation -> Repetition ...
+//        Repetition    -> Element
+//        Group, Option -> Alternation
+//    RuleName、CharVal、NumVal、ProseVal没有子节点
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Rule:
+		Walk(v, n.GetRuleName())
+		Walk(v, n.GetElements())
+	case *Elements:
+		Walk(v, n.GetAlternation())
+	case *Alternation:
+		for _, concatenation := range n.GetConcatenations() {
+			Walk(v, concatenation)
+		}
+	case *Concatenation:
+		for _, repetition := range n.GetRepetitions() {
+			Walk(v, repetition)
+		}
+	case *Repetition:
+		Walk(v, n.GetElement())
+	case *Group:
+		Walk(v, n.GetAlternation())
+	case *Option:
+		Walk(v, n.GetAlternation())
+	case *RuleName, *CharVal, *NumVal, *ProseVal:
+		//    叶子节点
+	default:
+		panic(fmt.Sprintf("abnf.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (this inspector) Visit(node Node) Visitor {
+	if this(node) {
+		return this
+	}
+	return nil
+}
+
+//    按深度优先的顺序遍历以node为根的语法树，对每个节点调用f(node)，
+//    f返回false时不再遍历该节点的子节点。每个节点的子节点遍历完成后调用f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+//    按定义顺序遍历grammar中的每条规则
+func WalkGrammar(v Visitor, grammar *Grammar) {
+	for _, rule := range grammar.GetRules() {
+		Walk(v, rule)
+	}
+}
@@ -0,0 +1,65 @@
+package automata
+
+import (
+	"sort"
+)
+
+//闭区间[low, high]表示的一段输入字节，用作迁移函数的键值，
+//这样%x00-FF这样的范围只需要一个映射，而不是256个
+type ByteRange struct {
+	low  int
+	high int
+}
+
+func NewByteRange(low, high int) ByteRange {
+	if low > high {
+		low, high = high, low
+	}
+	return ByteRange{low, high}
+}
+
+func (this ByteRange) GetLow() int { return this.low }
+
+func (this ByteRange) GetHigh() int { return this.high }
+
+func (this ByteRange) Contains(input int) bool {
+	return input >= this.low && input <= this.high
+}
+
+//把若干个可能相互重叠的区间切分为互不相交的基本区间，
+//同一个基本区间内的所有字节在这些区间下的归属完全相同，
+//因此子集构造和最小化只需要对每个基本区间取一个代表字节计算迁移
+func disjointRanges(ranges []ByteRange) []ByteRange {
+	//扫描线：在区间的下界覆盖数加一，在上界之后减一，覆盖数大于0的基本区间才会被输出
+	delta := make(map[int]int)
+	for _, r := range ranges {
+		delta[r.low]++
+		delta[r.high+1]--
+	}
+	bounds := make([]int, 0, len(delta))
+	for p := range delta {
+		bounds = append(bounds, p)
+	}
+	sort.Ints(bounds)
+
+	result := make([]ByteRange, 0)
+	covered := 0
+	for i := 0; i+1 < len(bounds); i++ {
+		covered += delta[bounds[i]]
+		if covered > 0 {
+			result = append(result, ByteRange{bounds[i], bounds[i+1] - 1})
+		}
+	}
+	return result
+}
+
+//把区间展开为字节，去重并排序
+func bytesOf(ranges []ByteRange) []int {
+	seen := make(map[int]bool)
+	for _, r := range ranges {
+		for input := r.low; input <= r.high; input++ {
+			seen[input] = true
+		}
+	}
+	return sortedInputs(seen)
+}
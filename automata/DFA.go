@@ -1,6 +1,104 @@
 package automata
 
-import (
+import ()
 
-)
+//DFA的输入符号是字节，迁移表的每一行有256列
+const DFA_ALPHABET_SIZE = 256
 
+//迁移表中表示没有下一状态（死状态）的值
+const DFA_DEAD_STATE = -1
+
+type DFA struct {
+	//开始状态startState
+	startState *DFAState
+
+	//所有状态，下标即状态标识
+	states []*DFAState
+}
+
+func NewDFA(startState *DFAState, states []*DFAState) *DFA {
+	this := &DFA{}
+	this.startState = startState
+	this.states = states
+	return this
+}
+
+func (this *DFA) GetStartState() *DFAState { return this.startState }
+
+func (this *DFA) GetStates() []*DFAState { return this.states }
+
+func (this *DFA) GetState(id int) *DFAState { return this.states[id] }
+
+func (this *DFA) Accept(state *DFAState) bool { return state != nil && state.IsAccepting() }
+
+func (this *DFA) GetAcceptingStates() []*DFAState {
+	accepting := make([]*DFAState, 0)
+	for _, state := range this.states {
+		if state.IsAccepting() {
+			accepting = append(accepting, state)
+		}
+	}
+	return accepting
+}
+
+//返回迁移表，table[i][c]是状态i在输入c下的下一状态标识，没有迁移时为DFA_DEAD_STATE
+func (this *DFA) GetTransitionTable() [][DFA_ALPHABET_SIZE]int {
+	table := make([][DFA_ALPHABET_SIZE]int, len(this.states))
+	for i, state := range this.states {
+		for c := 0; c < DFA_ALPHABET_SIZE; c++ {
+			table[i][c] = DFA_DEAD_STATE
+		}
+		for key, next := range state.GetTransitions() {
+			for input := key.GetLow(); input <= key.GetHigh() && input < DFA_ALPHABET_SIZE; input++ {
+				if input >= 0 {
+					table[i][input] = next.GetId()
+				}
+			}
+		}
+	}
+	return table
+}
+
+//返回每个状态的接收标志，accepting[i]表示状态i是否为接收状态
+func (this *DFA) GetAcceptingFlags() []bool {
+	accepting := make([]bool, len(this.states))
+	for i, state := range this.states {
+		accepting[i] = state.IsAccepting()
+	}
+	return accepting
+}
+
+//判断整个输入是否被DFA接受
+func (this *DFA) Match(input []byte) bool {
+	current := this.startState
+	for _, b := range input {
+		current = current.GetTransition(int(b))
+		if current == nil {
+			return false
+		}
+	}
+	return current.IsAccepting()
+}
+
+//匹配整个输入，并返回被接受的最长前缀以及匹配失败时卡住的位置和期待的字节
+func (this *DFA) MatchPrefix(input []byte) *MatchResult {
+	current := this.startState
+	longest := -1
+	if current.IsAccepting() {
+		longest = 0
+	}
+	for i, b := range input {
+		next := current.GetTransition(int(b))
+		if next == nil {
+			return NewMatchResult(false, longest, i, current.Expected())
+		}
+		current = next
+		if current.IsAccepting() {
+			longest = i + 1
+		}
+	}
+	if current.IsAccepting() {
+		return NewMatchResult(true, longest, len(input), nil)
+	}
+	return NewMatchResult(false, longest, len(input), current.Expected())
+}
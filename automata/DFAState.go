@@ -1,6 +1,88 @@
 package automata
 
 import (
-
+	"sort"
 )
 
+type DFAState struct {
+	//状态标识，在所属DFA内从0开始连续编号
+	id int
+
+	//子集构造时，该DFA状态所对应的NFA状态集合，按标识从小到大排列
+	nfaStates []*NFAState
+
+	//迁移函数，键值是互不相交的输入字节区间，每个输入符号最多只有一个下一状态
+	transitions map[ByteRange]*DFAState
+
+	//迁移函数的索引，按输入区间从小到大排列，在第一次查找时生成，添加映射时作废
+	index []dfaTransition
+
+	//是否为接收状态
+	accepting bool
+}
+
+//索引中的一项：输入区间以及它的下一状态
+type dfaTransition struct {
+	key  ByteRange
+	next *DFAState
+}
+
+func NewDFAState(id int, nfaStates Set_NFAState, accepting bool) *DFAState {
+	return newDFAState(id, sortedStates(nfaStates), accepting)
+}
+
+//nfaStates须已按标识排序，子集构造直接使用排序后的状态列表，不再为每个DFA状态建立Set_NFAState
+func newDFAState(id int, nfaStates []*NFAState, accepting bool) *DFAState {
+	this := &DFAState{}
+	this.id = id
+	this.nfaStates = nfaStates
+	this.transitions = make(map[ByteRange]*DFAState)
+	this.accepting = accepting
+	return this
+}
+
+func (this *DFAState) GetId() int { return this.id }
+
+func (this *DFAState) GetNFAStates() Set_NFAState {
+	states := make(Set_NFAState, len(this.nfaStates))
+	for _, state := range this.nfaStates {
+		states[state] = state
+	}
+	return states
+}
+
+func (this *DFAState) GetTransitions() map[ByteRange]*DFAState { return this.transitions }
+
+func (this *DFAState) IsAccepting() bool { return this.accepting }
+
+//向迁移函数添加一个映射，调用者需保证新的区间与已有的区间互不相交
+func (this *DFAState) AddTransit(low, high int, next *DFAState) *DFAState {
+	this.transitions[NewByteRange(low, high)] = next
+	this.index = nil
+	return next
+}
+
+//返回迁移函数，若没有相应的映射则返回nil
+func (this *DFAState) GetTransition(input int) *DFAState {
+	if this.index == nil {
+		this.index = make([]dfaTransition, 0, len(this.transitions))
+		for key, next := range this.transitions {
+			this.index = append(this.index, dfaTransition{key, next})
+		}
+		sort.Slice(this.index, func(i, j int) bool { return this.index[i].key.low < this.index[j].key.low })
+	}
+	i := sort.Search(len(this.index), func(i int) bool { return this.index[i].key.high >= input })
+	if i < len(this.index) && this.index[i].key.Contains(input) {
+		return this.index[i].next
+	}
+	return nil
+}
+
+//该状态下可以接受的所有输入字节
+func (this *DFAState) Expected() []int {
+	ranges := make([]ByteRange, 0, len(this.transitions))
+	for key := range this.transitions {
+		ranges = append(ranges, key)
+	}
+	return bytesOf(ranges)
+}
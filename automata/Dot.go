@@ -0,0 +1,140 @@
+package automata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+//把一组输入字节合并为连续的区间，生成边的标签，例如"0x2D, [0x41-0x5A]"
+func rangeLabel(inputs []int) string {
+	return formatInputs(inputs, "[0x%02X-0x%02X]")
+}
+
+//把排好序的字节合并为连续的区间，单个字节格式为0x41，区间按rangeFormat格式化
+func formatInputs(inputs []int, rangeFormat string) string {
+	var s bytes.Buffer
+	for i := 0; i < len(inputs); {
+		j := i
+		for j+1 < len(inputs) && inputs[j+1] == inputs[j]+1 {
+			j++
+		}
+		if s.Len() > 0 {
+			s.WriteString(", ")
+		}
+		if i == j {
+			s.WriteString(fmt.Sprintf("0x%02X", inputs[i]))
+		} else {
+			s.WriteString(fmt.Sprintf(rangeFormat, inputs[i], inputs[j]))
+		}
+		i = j + 1
+	}
+	return s.String()
+}
+
+func writeDotHeader(w *bufio.Writer, name string) {
+	w.WriteString("digraph " + strconv.Quote(name) + " {\n")
+	w.WriteString("\trankdir=LR;\n")
+	w.WriteString("\tnode [shape=circle];\n")
+	w.WriteString("\t__start [shape=point];\n")
+}
+
+func writeDotState(w *bufio.Writer, id string, accepting bool) {
+	if accepting {
+		w.WriteString("\t" + id + " [shape=doublecircle, style=bold];\n")
+	} else {
+		w.WriteString("\t" + id + ";\n")
+	}
+}
+
+func writeDotEdge(w *bufio.Writer, from, to, label string) {
+	w.WriteString("\t" + from + " -> " + to + " [label=" + strconv.Quote(label) + "];\n")
+}
+
+func writeDotEpsilonEdge(w *bufio.Writer, from, to string) {
+	w.WriteString("\t" + from + " -> " + to + " [label=\"ε\", style=dashed, color=gray];\n")
+}
+
+func nfaDotId(state *NFAState) string { return "n" + strconv.Itoa(state.GetId()) }
+
+func dfaDotId(state *DFAState) string { return "d" + strconv.Itoa(state.GetId()) }
+
+//以Graphviz DOT格式输出NFA，边的标签是合并成区间的输入字节，
+//epsilon迁移用虚线表示，接收状态用双圈表示
+func (this *NFA) WriteDot(writer io.Writer, name string) error {
+	w := bufio.NewWriter(writer)
+	writeDotHeader(w, name)
+
+	states := make([]*NFAState, 0)
+	for _, state := range this.GetStateSet() {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].GetId() < states[j].GetId() })
+
+	for _, state := range states {
+		writeDotState(w, nfaDotId(state), this.Accept(state))
+	}
+	w.WriteString("\t__start -> " + nfaDotId(this.GetStartState()) + ";\n")
+
+	for _, state := range states {
+		//把到达同一个下一状态的输入字节合并为一条边
+		inputs := make(map[*NFAState][]ByteRange)
+		for key, next := range state.GetTransitions() {
+			for _, target := range next {
+				inputs[target] = append(inputs[target], key)
+			}
+		}
+		targets := make([]*NFAState, 0, len(inputs))
+		for target := range inputs {
+			targets = append(targets, target)
+		}
+		sort.Slice(targets, func(i, j int) bool { return targets[i].GetId() < targets[j].GetId() })
+		for _, target := range targets {
+			writeDotEdge(w, nfaDotId(state), nfaDotId(target), rangeLabel(bytesOf(inputs[target])))
+		}
+
+		epsilons := make([]*NFAState, 0, len(state.GetEpsilonTransition()))
+		for _, target := range state.GetEpsilonTransition() {
+			epsilons = append(epsilons, target)
+		}
+		sort.Slice(epsilons, func(i, j int) bool { return epsilons[i].GetId() < epsilons[j].GetId() })
+		for _, target := range epsilons {
+			writeDotEpsilonEdge(w, nfaDotId(state), nfaDotId(target))
+		}
+	}
+
+	w.WriteString("}\n")
+	return w.Flush()
+}
+
+//以Graphviz DOT格式输出DFA，边的标签是合并成区间的输入字节，接收状态用双圈表示
+func (this *DFA) WriteDot(writer io.Writer, name string) error {
+	w := bufio.NewWriter(writer)
+	writeDotHeader(w, name)
+
+	for _, state := range this.states {
+		writeDotState(w, dfaDotId(state), state.IsAccepting())
+	}
+	w.WriteString("\t__start -> " + dfaDotId(this.startState) + ";\n")
+
+	for _, state := range this.states {
+		inputs := make(map[*DFAState][]ByteRange)
+		for key, next := range state.GetTransitions() {
+			inputs[next] = append(inputs[next], key)
+		}
+		targets := make([]*DFAState, 0, len(inputs))
+		for target := range inputs {
+			targets = append(targets, target)
+		}
+		sort.Slice(targets, func(i, j int) bool { return targets[i].GetId() < targets[j].GetId() })
+		for _, target := range targets {
+			writeDotEdge(w, dfaDotId(state), dfaDotId(target), rangeLabel(bytesOf(inputs[target])))
+		}
+	}
+
+	w.WriteString("}\n")
+	return w.Flush()
+}
@@ -0,0 +1,66 @@
+package automata
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+//自动机匹配的详细结果：
+//是否接受整个输入、被接受的最长前缀长度，
+//以及匹配失败时自动机卡住的位置和在该位置可以接受的字节集合
+type MatchResult struct {
+	matched  bool
+	longest  int
+	offset   int
+	expected []int
+}
+
+func NewMatchResult(matched bool, longest, offset int, expected []int) *MatchResult {
+	this := &MatchResult{}
+	this.matched = matched
+	this.longest = longest
+	this.offset = offset
+	this.expected = expected
+	return this
+}
+
+//整个输入是否被接受
+func (this *MatchResult) IsMatched() bool { return this.matched }
+
+//被接受的最长前缀的长度，没有任何前缀（包括空串）被接受时返回-1
+func (this *MatchResult) GetLongestPrefix() int { return this.longest }
+
+//自动机卡住的字节偏移：若某个字节无法迁移，则是该字节的下标；
+//若读完了输入却停在非接收状态，则是输入的长度
+func (this *MatchResult) GetOffset() int { return this.offset }
+
+//在GetOffset()处可以接受的字节，从小到大排列
+func (this *MatchResult) GetExpected() []int { return this.expected }
+
+func (this *MatchResult) String() string {
+	if this.matched {
+		return "matched " + strconv.Itoa(this.offset) + " bytes"
+	}
+
+	var s bytes.Buffer
+	s.WriteString("byte " + strconv.Itoa(this.offset) + ": ")
+	if len(this.expected) == 0 {
+		s.WriteString("expected end of input")
+		return s.String()
+	}
+	s.WriteString("expected one of [")
+	s.WriteString(formatInputs(this.expected, "0x%02X-0x%02X"))
+	s.WriteString("]")
+	return s.String()
+}
+
+//对一组迁移函数的输入符号去重并排序
+func sortedInputs(inputs map[int]bool) []int {
+	expected := make([]int, 0, len(inputs))
+	for input := range inputs {
+		expected = append(expected, input)
+	}
+	sort.Ints(expected)
+	return expected
+}
@@ -0,0 +1,248 @@
+package automata
+
+import (
+	"sort"
+)
+
+//DFA最小化的结果，除了最小化后的DFA之外，还记录原DFA状态被合并到了哪个等价类
+type DFAMinimization struct {
+	//最小化后的DFA
+	dfa *DFA
+
+	//classes[i]是最小DFA中状态i所对应的原DFA状态标识（从小到大排列）
+	classes [][]int
+
+	//无法到达接收状态的原DFA状态，它们与死状态等价，在最小DFA中被删除
+	removed []int
+
+	//原DFA中状态的总数
+	original int
+}
+
+func (this *DFAMinimization) GetDFA() *DFA { return this.dfa }
+
+func (this *DFAMinimization) GetClasses() [][]int { return this.classes }
+
+//返回原DFA状态id所属的等价类（即最小DFA中的状态标识），被删除的状态返回DFA_DEAD_STATE
+func (this *DFAMinimization) GetClassOf(id int) int {
+	for i, class := range this.classes {
+		for _, member := range class {
+			if member == id {
+				return i
+			}
+		}
+	}
+	return DFA_DEAD_STATE
+}
+
+func (this *DFAMinimization) GetRemovedStates() []int { return this.removed }
+
+//被合并或删除的状态个数，即原DFA状态数减去最小DFA状态数
+func (this *DFAMinimization) GetMergedCount() int {
+	return this.original - len(this.dfa.GetStates())
+}
+
+//Hopcroft算法：先把状态划分为接收状态和非接收状态两个等价类，
+//然后不断用某个等价类A在输入c下的前驱集合X去切分其他等价类，直到不能再切分为止。
+//原DFA的迁移函数是部分函数，这里补充一个死状态（标识为n），使迁移函数成为全函数，
+//与死状态等价的状态最终被删除。
+func MinimizeDFA(dfa *DFA) *DFAMinimization {
+	states := dfa.GetStates()
+	n := len(states)
+	dead := n
+
+	//字母表只取实际出现过的输入区间并切分为互不相交的基本区间，其余符号在任何状态下都迁移到死状态，不影响划分
+	ranges := make([]ByteRange, 0)
+	for _, state := range states {
+		for key := range state.GetTransitions() {
+			ranges = append(ranges, key)
+		}
+	}
+	alphabet := disjointRanges(ranges)
+
+	//逆迁移函数：inverse[k][t]是在输入区间alphabet[k]下迁移到t的所有状态，只记录实际存在的迁移，
+	//因此占用的空间与迁移的个数成正比，而不是|alphabet|*n
+	inverse := make([]map[int][]int, len(alphabet))
+	for k := range alphabet {
+		inverse[k] = make(map[int][]int)
+	}
+	for s, state := range states {
+		for key, next := range state.GetTransitions() {
+			k := sort.Search(len(alphabet), func(k int) bool { return alphabet[k].high >= key.GetLow() })
+			for ; k < len(alphabet) && alphabet[k].low <= key.GetHigh(); k++ {
+				inverse[k][next.GetId()] = append(inverse[k][next.GetId()], s)
+			}
+		}
+	}
+	//死状态的前驱是没有该输入的迁移的状态以及死状态本身，在用到时才计算
+	predecessors := func(k, t int) []int {
+		if t != dead {
+			return inverse[k][t]
+		}
+		result := make([]int, 0)
+		for s := 0; s < n; s++ {
+			if states[s].GetTransition(alphabet[k].low) == nil {
+				result = append(result, s)
+			}
+		}
+		return append(result, dead)
+	}
+
+	//初始划分
+	blocks := make([][]int, 0)
+	classOf := make([]int, n+1)
+	accepting := make([]int, 0)
+	rejecting := make([]int, 0)
+	for s := 0; s < n; s++ {
+		if states[s].IsAccepting() {
+			accepting = append(accepting, s)
+		} else {
+			rejecting = append(rejecting, s)
+		}
+	}
+	rejecting = append(rejecting, dead)
+	for _, block := range [][]int{accepting, rejecting} {
+		if len(block) == 0 {
+			continue
+		}
+		for _, s := range block {
+			classOf[s] = len(blocks)
+		}
+		blocks = append(blocks, block)
+	}
+
+	//待处理的切分者集合
+	waiting := make(map[int]bool)
+	worklist := make([]int, 0)
+	push := func(b int) {
+		if !waiting[b] {
+			waiting[b] = true
+			worklist = append(worklist, b)
+		}
+	}
+	if len(blocks) == 2 {
+		if len(blocks[0]) <= len(blocks[1]) {
+			push(0)
+		} else {
+			push(1)
+		}
+	}
+
+	for len(worklist) > 0 {
+		a := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		waiting[a] = false
+		splitter := append([]int(nil), blocks[a]...)
+
+		for k := range alphabet {
+			//X：在输入区间alphabet[k]下迁移到splitter中的状态
+			marked := make(map[int][]int)
+			for _, t := range splitter {
+				for _, s := range predecessors(k, t) {
+					marked[classOf[s]] = append(marked[classOf[s]], s)
+				}
+			}
+
+			touched := make([]int, 0, len(marked))
+			for b := range marked {
+				touched = append(touched, b)
+			}
+			sort.Ints(touched)
+
+			for _, b := range touched {
+				in := marked[b]
+				if len(in) == len(blocks[b]) {
+					continue
+				}
+				inSet := make(map[int]bool, len(in))
+				for _, s := range in {
+					inSet[s] = true
+				}
+				out := make([]int, 0, len(blocks[b])-len(in))
+				for _, s := range blocks[b] {
+					if !inSet[s] {
+						out = append(out, s)
+					}
+				}
+
+				//Y被切分为Y∩X（保留原编号）和Y\X（新编号）
+				sort.Ints(in)
+				blocks[b] = in
+				nb := len(blocks)
+				blocks = append(blocks, out)
+				for _, s := range out {
+					classOf[s] = nb
+				}
+
+				if waiting[b] || len(out) <= len(in) {
+					push(nb)
+				} else {
+					push(b)
+				}
+			}
+		}
+	}
+
+	return buildMinimalDFA(dfa, blocks, classOf, dead)
+}
+
+//根据最终划分构造最小DFA，新状态按各等价类中最小的原状态标识排序，
+//因此原DFA的开始状态（标识0）所在的等价类仍然是标识为0的开始状态
+func buildMinimalDFA(dfa *DFA, blocks [][]int, classOf []int, dead int) *DFAMinimization {
+	states := dfa.GetStates()
+	this := &DFAMinimization{}
+	this.original = len(states)
+	this.classes = make([][]int, 0)
+	this.removed = make([]int, 0)
+
+	deadClass := classOf[dead]
+	kept := make([]int, 0)
+	for b, block := range blocks {
+		sort.Ints(block)
+		if b == deadClass {
+			for _, s := range block {
+				if s != dead {
+					this.removed = append(this.removed, s)
+				}
+			}
+			continue
+		}
+		kept = append(kept, b)
+	}
+	sort.Slice(kept, func(i, j int) bool { return blocks[kept[i]][0] < blocks[kept[j]][0] })
+
+	newId := make(map[int]int)
+	minimal := make([]*DFAState, len(kept))
+	for i, b := range kept {
+		newId[b] = i
+		block := blocks[b]
+		nfaStates := make(Set_NFAState)
+		for _, s := range block {
+			for _, state := range states[s].nfaStates {
+				nfaStates[state] = state
+			}
+		}
+		minimal[i] = NewDFAState(i, nfaStates, states[block[0]].IsAccepting())
+		this.classes = append(this.classes, append([]int(nil), block...))
+	}
+
+	for i, b := range kept {
+		representative := states[blocks[b][0]]
+		for key, next := range representative.GetTransitions() {
+			if target, present := newId[classOf[next.GetId()]]; present {
+				minimal[i].AddTransit(key.GetLow(), key.GetHigh(), minimal[target])
+			}
+		}
+	}
+
+	//语言为空时所有状态都与死状态等价，返回只有一个非接收开始状态的DFA
+	if len(minimal) == 0 {
+		start := NewDFAState(0, make(Set_NFAState), false)
+		this.dfa = NewDFA(start, []*DFAState{start})
+		return this
+	}
+
+	startState := minimal[newId[classOf[dfa.GetStartState().GetId()]]]
+	this.dfa = NewDFA(startState, minimal)
+	return this
+}
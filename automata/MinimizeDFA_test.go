@@ -0,0 +1,153 @@
+package automata
+
+import (
+	"reflect"
+	"testing"
+)
+
+//按编号构造DFA，accepting中的状态为接收状态，状态0为开始状态
+func newTestDFA(n int, accepting ...int) []*DFAState {
+	states := make([]*DFAState, n)
+	for i := range states {
+		states[i] = NewDFAState(i, make(Set_NFAState), false)
+	}
+	for _, i := range accepting {
+		states[i].accepting = true
+	}
+	return states
+}
+
+//alphabet上长度不超过maxLength的所有输入
+func inputsUpTo(alphabet []byte, maxLength int) [][]byte {
+	inputs := [][]byte{{}}
+	current := [][]byte{{}}
+	for length := 1; length <= maxLength; length++ {
+		next := make([][]byte, 0)
+		for _, prefix := range current {
+			for _, b := range alphabet {
+				next = append(next, append(append([]byte(nil), prefix...), b))
+			}
+		}
+		inputs = append(inputs, next...)
+		current = next
+	}
+	return inputs
+}
+
+func assertSameLanguage(t *testing.T, want func([]byte) bool, got *DFA, alphabet []byte, maxLength int) {
+	for _, input := range inputsUpTo(alphabet, maxLength) {
+		if want(input) != got.Match(input) {
+			t.Errorf("%q: accepted = %v, want %v", input, got.Match(input), want(input))
+		}
+	}
+}
+
+func TestMinimizeDFA(t *testing.T) {
+	//龙书中(a|b)*abb的DFA，A和C等价
+	abb := newTestDFA(5, 4)
+	for _, state := range abb {
+		state.AddTransit('a', 'a', abb[1])
+	}
+	abb[0].AddTransit('b', 'b', abb[2])
+	abb[1].AddTransit('b', 'b', abb[3])
+	abb[2].AddTransit('b', 'b', abb[2])
+	abb[3].AddTransit('b', 'b', abb[4])
+	abb[4].AddTransit('b', 'b', abb[2])
+
+	//部分DFA：状态1和2只是在'd'上不同，1在'd'上迁移到死状态，因此不等价；
+	//状态4和5无法到达接收状态，与死状态等价
+	partial := newTestDFA(6, 3)
+	partial[0].AddTransit('a', 'a', partial[1])
+	partial[0].AddTransit('b', 'b', partial[2])
+	partial[0].AddTransit('d', 'd', partial[4])
+	partial[1].AddTransit('c', 'c', partial[3])
+	partial[2].AddTransit('c', 'd', partial[3])
+	partial[4].AddTransit('a', 'a', partial[5])
+	partial[5].AddTransit('a', 'a', partial[4])
+
+	//区间迁移：[a-m]和[n-z]到达的两个接收状态等价
+	ranges := newTestDFA(3, 1, 2)
+	ranges[0].AddTransit('a', 'm', ranges[1])
+	ranges[0].AddTransit('n', 'z', ranges[2])
+
+	//空语言
+	empty := newTestDFA(2)
+	empty[0].AddTransit('a', 'a', empty[1])
+
+	tests := []struct {
+		name     string
+		states   []*DFAState
+		alphabet []byte
+		classes  [][]int
+		removed  []int
+	}{
+		{"abb", abb, []byte("ab"), [][]int{{0, 2}, {1}, {3}, {4}}, []int{}},
+		{"partial", partial, []byte("abcd"), [][]int{{0}, {1}, {2}, {3}}, []int{4, 5}},
+		{"ranges", ranges, []byte("amnz0"), [][]int{{0}, {1, 2}}, []int{}},
+		{"empty", empty, []byte("a"), [][]int{}, []int{0, 1}},
+	}
+	for _, test := range tests {
+		dfa := NewDFA(test.states[0], test.states)
+		minimization := MinimizeDFA(dfa)
+		minimal := minimization.GetDFA()
+		if !reflect.DeepEqual(minimization.GetClasses(), test.classes) {
+			t.Errorf("%s: classes = %v, want %v", test.name, minimization.GetClasses(), test.classes)
+		}
+		if !reflect.DeepEqual(minimization.GetRemovedStates(), test.removed) {
+			t.Errorf("%s: removed = %v, want %v", test.name, minimization.GetRemovedStates(), test.removed)
+		}
+		size := len(test.classes)
+		if size == 0 {
+			//空语言只剩一个非接收的开始状态
+			size = 1
+		}
+		if len(minimal.GetStates()) != size {
+			t.Errorf("%s: %d states, want %d", test.name, len(minimal.GetStates()), size)
+		}
+		if minimization.GetMergedCount() != len(test.states)-size {
+			t.Errorf("%s: merged %d, want %d", test.name, minimization.GetMergedCount(), len(test.states)-size)
+		}
+		if minimal.GetStartState().GetId() != 0 {
+			t.Errorf("%s: start state is %d", test.name, minimal.GetStartState().GetId())
+		}
+		for i, class := range test.classes {
+			for _, id := range class {
+				if minimization.GetClassOf(id) != i {
+					t.Errorf("%s: class of %d = %d, want %d", test.name, id, minimization.GetClassOf(id), i)
+				}
+			}
+		}
+		for _, id := range test.removed {
+			if minimization.GetClassOf(id) != DFA_DEAD_STATE {
+				t.Errorf("%s: class of removed state %d = %d", test.name, id, minimization.GetClassOf(id))
+			}
+		}
+		assertSameLanguage(t, dfa.Match, minimal, test.alphabet, 6)
+	}
+}
+
+func TestMinimizeNFA2DFA(t *testing.T) {
+	//(a|b)*abb的Thompson NFA
+	start := NewNFAState()
+	loop := NewNFAState()
+	start.AddTransitEpsilon(loop)
+	loop.AddTransitInt2('a', loop)
+	loop.AddTransitInt2('b', loop)
+	accepting := loop.AddTransitInt1('a').AddTransitInt1('b').AddTransitInt1('b')
+	nfa := NewNFA2(start, accepting)
+
+	dfa := NFA2DFA(nfa)
+	assertSameLanguage(t, nfa.Match, dfa, []byte("abc"), 7)
+	minimal := MinimizeDFA(dfa).GetDFA()
+	if len(minimal.GetStates()) != 4 {
+		t.Errorf("%d states, want 4", len(minimal.GetStates()))
+	}
+	assertSameLanguage(t, nfa.Match, minimal, []byte("abc"), 7)
+
+	if _, err := NFA2DFA2(nfa, 2); err == nil || err.Error() != "DFA has more than 2 states" {
+		t.Errorf("NFA2DFA2 does not stop at 2 states: %v", err)
+	}
+	if _, err := NFA2DFA2(nfa, len(dfa.GetStates())); err != nil {
+		t.Errorf("NFA2DFA2: %v", err)
+	}
+}
@@ -1,6 +1,191 @@
 package automata
 
 import (
-
+	"fmt"
+	"sort"
 )
 
+//求状态集合的epsilon闭包，即从集合中的状态出发，只经过空字符迁移所能到达的所有状态（包括自身）
+func EpsilonClosure(states Set_NFAState) Set_NFAState {
+	closure := make(Set_NFAState)
+	stack := make([]*NFAState, 0, len(states))
+	for _, state := range states {
+		closure[state] = state
+		stack = append(stack, state)
+	}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range current.GetEpsilonTransition() {
+			if _, present := closure[next]; !present {
+				closure[next] = next
+				stack = append(stack, next)
+			}
+		}
+	}
+	return closure
+}
+
+//求状态集合在输入符号input下的迁移结果（不含epsilon闭包）
+func Move(states Set_NFAState, input int) Set_NFAState {
+	result := make(Set_NFAState)
+	for _, state := range states {
+		for _, next := range state.GetTransition(input) {
+			result[next] = next
+		}
+	}
+	return result
+}
+
+//子集构造中求epsilon闭包的工具，用NFA状态标识下标的数组代替map判断状态是否已加入闭包，
+//mark[id] == generation表示状态id已经在本次求出的闭包中
+type closureBuilder struct {
+	mark       []int
+	generation int
+	stack      []*NFAState
+}
+
+func newClosureBuilder() *closureBuilder {
+	this := &closureBuilder{}
+	this.mark = make([]int, NFAState_COUNT)
+	return this
+}
+
+//求状态列表（可以有重复）的epsilon闭包，结果按状态标识从小到大排列
+func (this *closureBuilder) closure(states []*NFAState) []*NFAState {
+	this.generation++
+	result := make([]*NFAState, 0, len(states))
+	this.stack = this.stack[:0]
+	visit := func(state *NFAState) {
+		if this.mark[state.id] != this.generation {
+			this.mark[state.id] = this.generation
+			result = append(result, state)
+			this.stack = append(this.stack, state)
+		}
+	}
+	for _, state := range states {
+		visit(state)
+	}
+	for len(this.stack) > 0 {
+		current := this.stack[len(this.stack)-1]
+		this.stack = this.stack[:len(this.stack)-1]
+		for _, next := range current.epsilonTransition {
+			visit(next)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+//按标识排序的状态列表的散列值（FNV-1a），散列相同的集合再逐个比较标识
+func hashOf(states []*NFAState) uint64 {
+	hash := uint64(14695981039346656037)
+	for _, state := range states {
+		hash ^= uint64(state.id)
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+func sameStates(a, b []*NFAState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//子集构造法：DFA的每个状态对应NFA的一个状态集合，
+//开始状态是NFA开始状态的epsilon闭包，
+//对每个未处理的DFA状态和每个输入符号，求move之后的epsilon闭包作为下一状态，
+//包含NFA接收状态的集合即为DFA的接收状态
+func NFA2DFA(nfa *NFA) *DFA {
+	dfa, _ := NFA2DFA2(nfa, 0)
+	return dfa
+}
+
+//同NFA2DFA，但DFA的状态数超过maxStates时停止构造并返回错误，maxStates为0表示不限制。
+//子集构造在最坏情况下产生指数个状态，对大的文法应当给出限制
+func NFA2DFA2(nfa *NFA, maxStates int) (*DFA, error) {
+	states := make([]*DFAState, 0)
+	dstates := make(map[uint64][]*DFAState)
+	builder := newClosureBuilder()
+
+	//返回状态集合所对应的DFA状态，不存在时创建一个新的
+	stateOf := func(nfaStates []*NFAState) *DFAState {
+		hash := hashOf(nfaStates)
+		for _, dstate := range dstates[hash] {
+			if sameStates(dstate.nfaStates, nfaStates) {
+				return dstate
+			}
+		}
+		accepting := false
+		for _, state := range nfaStates {
+			if nfa.Accept(state) {
+				accepting = true
+				break
+			}
+		}
+		dstate := newDFAState(len(states), nfaStates, accepting)
+		states = append(states, dstate)
+		dstates[hash] = append(dstates[hash], dstate)
+		return dstate
+	}
+
+	startState := stateOf(builder.closure([]*NFAState{nfa.GetStartState()}))
+
+	//states同时作为未处理状态的队列使用
+	for index := 0; index < len(states); index++ {
+		current := states[index]
+
+		//把集合中所有状态的迁移切分为互不相交的基本区间，
+		//同一个基本区间内的字节迁移结果相同，一次扫描求出每个基本区间的move
+		transitions := make([]nfaTransition, 0)
+		ranges := make([]ByteRange, 0)
+		for _, state := range current.nfaStates {
+			for _, transition := range state.getIndex() {
+				transitions = append(transitions, transition)
+				ranges = append(ranges, transition.key)
+			}
+		}
+		alphabet := disjointRanges(ranges)
+		moves := make([][]*NFAState, len(alphabet))
+		for _, transition := range transitions {
+			k := sort.Search(len(alphabet), func(k int) bool { return alphabet[k].high >= transition.key.low })
+			for ; k < len(alphabet) && alphabet[k].low <= transition.key.high; k++ {
+				moves[k] = append(moves[k], transition.states...)
+			}
+		}
+
+		//相邻且到达同一状态的区间合并为一个映射
+		var last *DFAState
+		var low, high int
+		for k, key := range alphabet {
+			if len(moves[k]) == 0 {
+				continue
+			}
+			next := stateOf(builder.closure(moves[k]))
+			if last == next && high+1 == key.GetLow() {
+				high = key.GetHigh()
+				continue
+			}
+			if last != nil {
+				current.AddTransit(low, high, last)
+			}
+			last, low, high = next, key.GetLow(), key.GetHigh()
+		}
+		if last != nil {
+			current.AddTransit(low, high, last)
+		}
+		if maxStates > 0 && len(states) > maxStates {
+			return nil, fmt.Errorf("DFA has more than %d states", maxStates)
+		}
+	}
+
+	return NewDFA(startState, states), nil
+}
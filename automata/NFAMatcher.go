@@ -0,0 +1,121 @@
+package automata
+
+import (
+	"bufio"
+	"io"
+)
+
+//NFA模拟器，逐个字节地维护NFA当前所处的状态集合（总是epsilon闭包），
+//不需要事先把NFA转换为DFA
+type NFAMatcher struct {
+	nfa     *NFA
+	current Set_NFAState
+}
+
+func NewNFAMatcher(nfa *NFA) *NFAMatcher {
+	this := &NFAMatcher{}
+	this.nfa = nfa
+	this.Reset()
+	return this
+}
+
+//回到开始状态的epsilon闭包
+func (this *NFAMatcher) Reset() {
+	start := make(Set_NFAState)
+	start[this.nfa.GetStartState()] = this.nfa.GetStartState()
+	this.current = EpsilonClosure(start)
+}
+
+func (this *NFAMatcher) GetCurrentStates() Set_NFAState { return this.current }
+
+//读入一个字节，返回读入之后状态集合是否非空；
+//状态集合一旦为空，之后的任何输入都不可能被接受
+func (this *NFAMatcher) Step(input byte) bool {
+	next := make(Set_NFAState)
+	for _, state := range this.current {
+		for _, target := range state.GetTransition(int(input)) {
+			next[target] = target
+		}
+	}
+	this.current = EpsilonClosure(next)
+	return len(this.current) > 0
+}
+
+//当前状态集合中是否包含接收状态，即已读入的输入是否被接受
+func (this *NFAMatcher) Accepting() bool {
+	for _, state := range this.current {
+		if this.nfa.Accept(state) {
+			return true
+		}
+	}
+	return false
+}
+
+//当前状态集合下可以接受的所有输入字节
+func (this *NFAMatcher) Expected() []int {
+	return expectedOf(this.current)
+}
+
+func expectedOf(states Set_NFAState) []int {
+	ranges := make([]ByteRange, 0)
+	for _, state := range states {
+		for key, next := range state.GetTransitions() {
+			if len(next) > 0 {
+				ranges = append(ranges, key)
+			}
+		}
+	}
+	return bytesOf(ranges)
+}
+
+//判断整个输入是否被NFA接受
+func (this *NFA) Match(input []byte) bool {
+	matcher := NewNFAMatcher(this)
+	for _, b := range input {
+		if !matcher.Step(b) {
+			return false
+		}
+	}
+	return matcher.Accepting()
+}
+
+//判断从reader读入的全部内容是否被NFA接受，状态集合变空时立即停止读取
+func (this *NFA) MatchReader(reader io.Reader) (bool, error) {
+	matcher := NewNFAMatcher(this)
+	buffered := bufio.NewReader(reader)
+	for {
+		b, err := buffered.ReadByte()
+		if err == io.EOF {
+			return matcher.Accepting(), nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !matcher.Step(b) {
+			return false, nil
+		}
+	}
+}
+
+//匹配整个输入，并返回被接受的最长前缀以及匹配失败时卡住的位置和期待的字节
+func (this *NFA) MatchPrefix(input []byte) *MatchResult {
+	matcher := NewNFAMatcher(this)
+	longest := -1
+	if matcher.Accepting() {
+		longest = 0
+	}
+	for i, b := range input {
+		//Step总是生成新的状态集合，保留读入之前的集合，只在卡住时才求期待的字节
+		previous := matcher.GetCurrentStates()
+		if !matcher.Step(b) {
+			return NewMatchResult(false, longest, i, expectedOf(previous))
+		}
+		if matcher.Accepting() {
+			longest = i + 1
+		}
+	}
+	if matcher.Accepting() {
+		return NewMatchResult(true, longest, len(input), nil)
+	}
+	return NewMatchResult(false, longest, len(input), matcher.Expected())
+}
@@ -0,0 +1,38 @@
+package automata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNFAMatchPrefix(t *testing.T) {
+	//"ab" / "abcd"
+	start := NewNFAState()
+	accepting := NewNFAState()
+	b := start.AddTransitInt1('a').AddTransitInt1('b')
+	b.AddTransitEpsilon(accepting)
+	b.AddTransitInt1('c').AddTransitInt2('d', accepting)
+	nfa := NewNFA2(start, accepting)
+
+	tests := []struct {
+		input    string
+		matched  bool
+		longest  int
+		offset   int
+		expected []int
+	}{
+		{"ab", true, 2, 2, nil},
+		{"abcd", true, 4, 4, nil},
+		{"", false, -1, 0, []int{'a'}},
+		{"abx", false, 2, 2, []int{'c'}},
+		{"abc", false, 2, 3, []int{'d'}},
+		{"x", false, -1, 0, []int{'a'}},
+	}
+	for _, test := range tests {
+		result := nfa.MatchPrefix([]byte(test.input))
+		if result.IsMatched() != test.matched || result.GetLongestPrefix() != test.longest ||
+			result.GetOffset() != test.offset || !reflect.DeepEqual(result.GetExpected(), test.expected) {
+			t.Errorf("%q: got %s", test.input, result.String())
+		}
+	}
+}
@@ -2,6 +2,7 @@ package automata
 
 import (
 	"bytes"
+	"sort"
 	//"container/list"
 )
 
@@ -12,14 +13,24 @@ type Set_NFAState map[*NFAState]*NFAState
 type NFAState struct { //implements Comparable<NFAState> {
 	//状态标识，每个NFA状态节点都有唯一的数值标识
 	id                int
-	transitions       map[int]Set_NFAState
+	transitions       map[ByteRange]Set_NFAState
 	epsilonTransition Set_NFAState
+
+	//迁移函数的索引，按输入区间从小到大排列，区间互不相交，
+	//在第一次查找时由transitions生成，添加映射时作废
+	index []nfaTransition
+}
+
+//索引中的一项：基本区间以及该区间内的输入字节所能到达的状态（按标识排序）
+type nfaTransition struct {
+	key    ByteRange
+	states []*NFAState
 }
 
 //在创建NFA状态对象的时候，通过静态变量生成唯一标识
 func NewNFAState() *NFAState {
 	this := &NFAState{}
-	this.transitions = make(map[int]Set_NFAState)
+	this.transitions = make(map[ByteRange]Set_NFAState)
 	this.epsilonTransition = make(Set_NFAState)
 	this.id = NFAState_COUNT
 	NFAState_COUNT++
@@ -30,8 +41,9 @@ func (this *NFAState) GetId() int { return this.id }
 
 //迁移函数，由于迁移函数需要两个输入：当前状态和输入符号，因此在一个状态对象内部，
 //迁移函数都是针对本对象的，只需要输入符号就可以了，这里通过Map接口实现迁移函数
+//映射的键值是输入字节的闭区间，区间之间可以重叠
 //protected Map<Integer, Set<NFAState>> transition = new HashMap<Integer, Set<NFAState>>();
-func (this *NFAState) GetTransitions() map[int]Set_NFAState { return this.transitions }
+func (this *NFAState) GetTransitions() map[ByteRange]Set_NFAState { return this.transitions }
 
 //空字符迁移函数，即从当前节点经过空字符输入所能够到达的下一个状态节点
 //protected Set<NFAState> epsilonTransition = new HashSet<NFAState>();
@@ -44,25 +56,37 @@ func (this *NFAState) AddTransitInt1(input int) *NFAState {
 
 //向迁移函数添加一个映射，给定下一个状态节点
 func (this *NFAState) AddTransitInt2(input int, next *NFAState) *NFAState {
-	states, present := this.transitions[input]
+	return this.AddTransitRange2(input, input, next)
+}
+
+//向迁移函数添加一个区间[low, high]的映射，不给定下一个状态节点
+func (this *NFAState) AddTransitRange1(low, high int) *NFAState {
+	return this.AddTransitRange2(low, high, NewNFAState())
+}
+
+//向迁移函数添加一个区间[low, high]的映射，给定下一个状态节点
+func (this *NFAState) AddTransitRange2(low, high int, next *NFAState) *NFAState {
+	key := NewByteRange(low, high)
+	states, present := this.transitions[key]
 	if !present {
 		states = make(Set_NFAState) //new HashSet<NFAState>();
-		this.transitions[input] = states
+		this.transitions[key] = states
 	}
 	states[next] = next
+	this.index = nil
 	return next
 }
 
 //向迁移函数添加一个映射，不给定下一个状态节点
 func (this *NFAState) AddTransitByte1(input byte) *NFAState {
-	return this.AddTransitInt2(int(input), NewNFAState())
+	return this.AddTransitByte2(input, NewNFAState())
 }
 
 //向迁移函数添加一个映射，给定下一个状态节点
 //假定我们的上下文无关文法是大小写不敏感的，当输入字符是char类型并且是字母时，
 //生成大写字母和小写字母两个映射
 func (this *NFAState) AddTransitByte2(input byte, next *NFAState) *NFAState {
-	if input >= 'a' && input <= 'z' && input >= 'A' && input <= 'Z' {
+	if (input >= 'a' && input <= 'z') || (input >= 'A' && input <= 'Z') {
 		var b [1]byte
 		b[0] = input
 		this.AddTransitInt2(int(bytes.ToUpper(b[:])[0]), next)
@@ -80,9 +104,53 @@ func (this *NFAState) AddTransitEpsilon(next *NFAState) *NFAState {
 	return next
 }
 
-//返回迁移函数
+//返回迁移函数，即所有包含输入符号的区间所映射到的状态的并集
 func (this *NFAState) GetTransition(input int) Set_NFAState {
-	return this.transitions[input]
+	result := make(Set_NFAState)
+	index := this.getIndex()
+	i := sort.Search(len(index), func(i int) bool { return index[i].key.high >= input })
+	if i < len(index) && index[i].key.Contains(input) {
+		for _, state := range index[i].states {
+			result[state] = state
+		}
+	}
+	return result
+}
+
+//把可能相互重叠的区间切分为互不相交的基本区间，每个基本区间映射到所有包含它的区间的状态的并集
+func (this *NFAState) getIndex() []nfaTransition {
+	if this.index != nil {
+		return this.index
+	}
+	ranges := make([]ByteRange, 0, len(this.transitions))
+	for key, states := range this.transitions {
+		if len(states) > 0 {
+			ranges = append(ranges, key)
+		}
+	}
+	this.index = make([]nfaTransition, 0, len(ranges))
+	for _, key := range disjointRanges(ranges) {
+		union := make(Set_NFAState)
+		for _, r := range ranges {
+			if r.Contains(key.low) {
+				for _, state := range this.transitions[r] {
+					union[state] = state
+				}
+			}
+		}
+		this.index = append(this.index, nfaTransition{key, sortedStates(union)})
+	}
+	return this.index
+}
+
+//按标识从小到大排列状态集合
+func sortedStates(states Set_NFAState) []*NFAState {
+	result := make([]*NFAState, 0, len(states))
+	for _, state := range states {
+		result = append(result, state)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
 }
 
 func (this *NFAState) GetNextStates() Set_NFAState {
@@ -0,0 +1,41 @@
+package automata
+
+import (
+	"testing"
+)
+
+func TestAddTransitByte2(t *testing.T) {
+	tests := []struct {
+		literal  string
+		accepted []string
+		rejected []string
+	}{
+		{"a", []string{"a", "A"}, []string{"b", "B", "!", "\x01"}},
+		{"Z", []string{"z", "Z"}, []string{"y", "Y", "z "}},
+		{"aB", []string{"ab", "aB", "Ab", "AB"}, []string{"a", "b"}},
+		//    字母两边的非字母与大小写字母只差0x20，但不能折叠
+		{"@", []string{"@"}, []string{"`"}},
+		{"[", []string{"["}, []string{"{"}},
+		{"`", []string{"`"}, []string{"@"}},
+		{"{", []string{"{"}, []string{"["}},
+		{"1", []string{"1"}, []string{"\x11", "Q"}},
+	}
+	for _, test := range tests {
+		start := NewNFAState()
+		current := start
+		for i := 0; i < len(test.literal); i++ {
+			current = current.AddTransitByte2(test.literal[i], NewNFAState())
+		}
+		nfa := NewNFA2(start, current)
+		for _, input := range test.accepted {
+			if !nfa.Match([]byte(input)) {
+				t.Errorf("%q: %q is not accepted", test.literal, input)
+			}
+		}
+		for _, input := range test.rejected {
+			if nfa.Match([]byte(input)) {
+				t.Errorf("%q: %q is accepted", test.literal, input)
+			}
+		}
+	}
+}